@@ -0,0 +1,279 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+
+	"github.com/kvartborg/vector"
+)
+
+// AIState is one state of an AIBehavior. The functions get called when an AIControlComponent enters the state, every
+// frame it's in it, and when it leaves it. States keep no data of their own (that lives on the AIControlComponent),
+// so a behavior can be shared between any number of NPCs.
+type AIState struct {
+	Name     string
+	OnEnter  func(ai *AIControlComponent)
+	OnUpdate func(ai *AIControlComponent)
+	OnExit   func(ai *AIControlComponent)
+}
+
+// AICondition is checked every frame to decide whether an AITransition should happen.
+type AICondition func(ai *AIControlComponent) bool
+
+// AITransition moves an AIControlComponent from the From state to the To state when Condition is true. A blank From
+// matches any state.
+type AITransition struct {
+	From, To  string
+	Condition AICondition
+}
+
+// AIBehavior is a state machine configuration; each enemy archetype is one AIBehavior.
+type AIBehavior struct {
+	Start       string
+	States      map[string]*AIState
+	Transitions []AITransition
+}
+
+func NewAIBehavior(start string, states ...*AIState) *AIBehavior {
+
+	behavior := &AIBehavior{
+		Start:  start,
+		States: map[string]*AIState{},
+	}
+
+	for _, state := range states {
+		behavior.States[state.Name] = state
+	}
+
+	if _, exists := behavior.States[start]; !exists {
+		log.Printf("AI behavior starts in state %q, which it doesn't have", start)
+	}
+
+	return behavior
+
+}
+
+// AddTransition adds a transition between two of the behavior's states. A transition to or from a state the behavior
+// doesn't have is logged and left out.
+func (behavior *AIBehavior) AddTransition(from, to string, condition AICondition) *AIBehavior {
+
+	for _, name := range []string{from, to} {
+		if _, exists := behavior.States[name]; name != "" && !exists {
+			log.Printf("AI transition from %q to %q left out; the behavior has no state %q", from, to, name)
+			return behavior
+		}
+	}
+
+	behavior.Transitions = append(behavior.Transitions, AITransition{From: from, To: to, Condition: condition})
+	return behavior
+
+}
+
+// NextState returns the state the AIControlComponent should move to, or a blank string if it should stay. Transitions
+// are checked in the order they were added.
+func (behavior *AIBehavior) NextState(ai *AIControlComponent) string {
+
+	for _, t := range behavior.Transitions {
+		if (t.From == "" || t.From == ai.State.Name) && t.To != ai.State.Name && t.Condition(ai) {
+			return t.To
+		}
+	}
+
+	return ""
+
+}
+
+// STATES
+
+const (
+	AIStateIdle   = "Idle"
+	AIStateWander = "Wander"
+	AIStatePatrol = "Patrol"
+	AIStateChase  = "Chase"
+	AIStateAttack = "Attack"
+	AIStateFlee   = "Flee"
+)
+
+func NewIdleState() *AIState {
+	return &AIState{
+		Name:     AIStateIdle,
+		OnUpdate: func(ai *AIControlComponent) { ai.Stop() },
+	}
+}
+
+// NewWanderState makes the NPC walk to random spots within radius cells of its home position.
+func NewWanderState(radius int) *AIState {
+	return &AIState{
+		Name: AIStateWander,
+		OnEnter: func(ai *AIControlComponent) {
+			ai.Goal = ai.RandomWalkableNear(ai.Home, radius)
+		},
+		OnUpdate: func(ai *AIControlComponent) {
+			if ai.Goal == nil || ai.AtPosition(ai.Goal, 4) || ai.StateTime%300 == 299 {
+				ai.Goal = ai.RandomWalkableNear(ai.Home, radius)
+				ai.Path = nil
+			}
+			if ai.Goal != nil {
+				ai.MoveTo(ai.Goal)
+			} else {
+				ai.Stop()
+			}
+		},
+	}
+}
+
+// NewPatrolState makes the NPC walk between its PatrolPoints in order. If it has none, it picks a few near its home.
+func NewPatrolState() *AIState {
+	return &AIState{
+		Name: AIStatePatrol,
+		OnEnter: func(ai *AIControlComponent) {
+			if len(ai.PatrolPoints) == 0 {
+				for i := 0; i < 3; i++ {
+					if p := ai.RandomWalkableNear(ai.Home, 6); p != nil {
+						ai.PatrolPoints = append(ai.PatrolPoints, p)
+					}
+				}
+			}
+		},
+		OnUpdate: func(ai *AIControlComponent) {
+			if len(ai.PatrolPoints) == 0 {
+				ai.Stop()
+				return
+			}
+			point := ai.PatrolPoints[ai.PatrolIndex%len(ai.PatrolPoints)]
+			if ai.AtPosition(point, 4) {
+				ai.PatrolIndex++
+				ai.Path = nil
+			} else {
+				ai.MoveTo(point)
+			}
+		},
+	}
+}
+
+func NewChaseState() *AIState {
+	return &AIState{
+		Name: AIStateChase,
+		OnUpdate: func(ai *AIControlComponent) {
+			if target := ai.TargetPosition(); target != nil {
+				ai.MoveTo(target)
+			} else {
+				ai.Stop()
+			}
+		},
+	}
+}
+
+// NewAttackState makes the NPC stand still and fire its weapon at the target.
+func NewAttackState() *AIState {
+	return &AIState{
+		Name: AIStateAttack,
+		OnUpdate: func(ai *AIControlComponent) {
+			ai.Stop()
+			if target := ai.TargetPosition(); target != nil {
+				ai.Fire(target.Sub(ai.Position()).Unit())
+			}
+		},
+	}
+}
+
+// NewFleeState makes the NPC run directly away from its target.
+func NewFleeState() *AIState {
+	return &AIState{
+		Name: AIStateFlee,
+		OnUpdate: func(ai *AIControlComponent) {
+			if target := ai.TargetPosition(); target != nil {
+				ai.MoveDirection = ai.Position().Sub(target).Unit()
+			} else {
+				ai.Stop()
+			}
+		},
+	}
+}
+
+// CONDITIONS
+
+func TargetCloserThan(distance float64) AICondition {
+	return func(ai *AIControlComponent) bool {
+		return ai.TargetDistance() < distance
+	}
+}
+
+func TargetFurtherThan(distance float64) AICondition {
+	return func(ai *AIControlComponent) bool {
+		return ai.TargetDistance() > distance
+	}
+}
+
+func TargetVisible() AICondition {
+	return func(ai *AIControlComponent) bool {
+		return ai.CanSeeTarget()
+	}
+}
+
+func TargetHidden() AICondition {
+	return func(ai *AIControlComponent) bool {
+		return !ai.CanSeeTarget()
+	}
+}
+
+// HealthBelow is true when the NPC's HealthComponent is under the given fraction of its max HP.
+func HealthBelow(fraction float64) AICondition {
+	return func(ai *AIControlComponent) bool {
+		if h := ai.GameObject.GetComponent(TypeHealthComponent); h != nil {
+			return h.(*HealthComponent).Fraction() < fraction
+		}
+		return false
+	}
+}
+
+// StateTimeOver is true once the NPC has been in its current state for more than the given number of frames.
+func StateTimeOver(frames int) AICondition {
+	return func(ai *AIControlComponent) bool {
+		return ai.StateTime > frames
+	}
+}
+
+func All(conditions ...AICondition) AICondition {
+	return func(ai *AIControlComponent) bool {
+		for _, c := range conditions {
+			if !c(ai) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func Any(conditions ...AICondition) AICondition {
+	return func(ai *AIControlComponent) bool {
+		for _, c := range conditions {
+			if c(ai) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RandomWalkableNear returns the center of a random walkable cell within radius cells of the position, or nil if it
+// couldn't find one.
+func (ai *AIControlComponent) RandomWalkableNear(pos vector.Vector, radius int) vector.Vector {
+
+	level := ai.GameObject.Level
+	cx, cy := level.CellAt(pos[0], pos[1])
+
+	for tries := 0; tries < 20; tries++ {
+
+		x := cx + rand.Intn(radius*2+1) - radius
+		y := cy + rand.Intn(radius*2+1) - radius
+
+		if cell := level.PathfindingGrid.Get(x, y); cell != nil && cell.Walkable && level.CellType(x, y).Tags == nil {
+			return vector.Vector{float64(x*16 + 8), float64(y*16 + 8)}
+		}
+
+	}
+
+	return nil
+
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+// stepBehavior runs the behavior's transitions for the given number of frames, without running the states
+// themselves, and returns the name of each state the NPC moves into.
+func stepBehavior(ai *AIControlComponent, frames int) []string {
+
+	changes := []string{}
+
+	for i := 0; i < frames; i++ {
+		if next := ai.Behavior.NextState(ai); next != "" {
+			ai.State = ai.Behavior.States[next]
+			ai.StateTime = 0
+			changes = append(changes, next)
+		} else {
+			ai.StateTime++
+		}
+	}
+
+	return changes
+
+}
+
+func TestHurtGuardSettles(t *testing.T) {
+
+	tests := []struct {
+		start string
+		want  []string
+	}{
+		// Far enough from a target it's forgotten about, a fleeing guard stops running and wanders off.
+		{AIStateFlee, []string{AIStateWander}},
+		{AIStateWander, []string{}},
+		{AIStatePatrol, []string{AIStateFlee, AIStateWander}},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.start, func(t *testing.T) {
+
+			guard := NewGameObject(nil)
+			health := NewHealthComponent(3)
+			ai := NewAIControlComponent(NewGuardBehavior())
+			guard.AddComponent(health, ai)
+			health.Damage(2)
+
+			ai.State = ai.Behavior.States[test.start]
+
+			changes := stepBehavior(ai, 600)
+
+			if len(changes) != len(test.want) {
+				t.Fatalf("went through states %v, want %v", changes, test.want)
+			}
+
+			for i := range changes {
+				if changes[i] != test.want[i] {
+					t.Fatalf("went through states %v, want %v", changes, test.want)
+				}
+			}
+
+		})
+
+	}
+
+}
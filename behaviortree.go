@@ -0,0 +1,489 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/kvartborg/vector"
+)
+
+type BTStatus int
+
+const (
+	BTSuccess BTStatus = iota
+	BTFailure
+	BTRunning
+)
+
+// BTAgent is what a behavior tree's leaf nodes act on. AIControlComponent is the BTAgent used in the game; anything
+// else implementing it (like a stub in a test) can run a tree without a Level or renderer.
+type BTAgent interface {
+	Position() vector.Vector
+	HomePosition() vector.Vector
+	MoveTo(goal vector.Vector)
+	AtPosition(pos vector.Vector, margin float64) bool
+	Stop()
+	Fire(direction vector.Vector) bool
+	TargetPosition() vector.Vector
+	CanSeeTarget() bool
+	RandomWalkableNear(pos vector.Vector, radius int) vector.Vector
+}
+
+// Blackboard is the memory a behavior tree's nodes share with each other.
+type Blackboard map[string]interface{}
+
+func (bb Blackboard) Vector(key string) vector.Vector {
+	if v, ok := bb[key].(vector.Vector); ok {
+		return v
+	}
+	return nil
+}
+
+type BTContext struct {
+	Agent      BTAgent
+	Blackboard Blackboard
+}
+
+type BTNode interface {
+	Tick(ctx *BTContext) BTStatus
+	Reset()
+}
+
+// BehaviorTree is one NPC's instance of a tree; nodes keep running state, so trees can't be shared between NPCs.
+type BehaviorTree struct {
+	Root       BTNode
+	Blackboard Blackboard
+}
+
+func NewBehaviorTree(root BTNode) *BehaviorTree {
+	return &BehaviorTree{Root: root, Blackboard: Blackboard{}}
+}
+
+func (bt *BehaviorTree) Tick(agent BTAgent) BTStatus {
+
+	status := bt.Root.Tick(&BTContext{Agent: agent, Blackboard: bt.Blackboard})
+
+	if status != BTRunning {
+		bt.Root.Reset()
+	}
+
+	return status
+
+}
+
+// COMPOSITES
+
+// Composites are reactive: every frame they tick their children from the first one on, so a condition early in a
+// sequence (or a higher-priority branch of a selector) can interrupt a child that's still running. Leaves should be
+// written so that ticking them again after they've succeeded is harmless.
+
+// BTSequence ticks its children in order, stopping at the first one that fails or is still running.
+type BTSequence struct {
+	Children []BTNode
+}
+
+func (n *BTSequence) Tick(ctx *BTContext) BTStatus {
+
+	for i, c := range n.Children {
+		if status := c.Tick(ctx); status != BTSuccess {
+			resetNodes(n.Children[i+1:])
+			return status
+		}
+	}
+
+	return BTSuccess
+
+}
+
+func (n *BTSequence) Reset() { resetNodes(n.Children) }
+
+// BTSelector ticks its children in order, stopping at the first one that succeeds or is still running.
+type BTSelector struct {
+	Children []BTNode
+}
+
+func (n *BTSelector) Tick(ctx *BTContext) BTStatus {
+
+	for i, c := range n.Children {
+		if status := c.Tick(ctx); status != BTFailure {
+			resetNodes(n.Children[i+1:])
+			return status
+		}
+	}
+
+	return BTFailure
+
+}
+
+func (n *BTSelector) Reset() { resetNodes(n.Children) }
+
+func resetNodes(nodes []BTNode) {
+	for _, n := range nodes {
+		n.Reset()
+	}
+}
+
+// DECORATORS
+
+// BTInverter turns its child's success into failure and vice versa.
+type BTInverter struct {
+	Child BTNode
+}
+
+func (n *BTInverter) Tick(ctx *BTContext) BTStatus {
+	switch n.Child.Tick(ctx) {
+	case BTSuccess:
+		return BTFailure
+	case BTFailure:
+		return BTSuccess
+	}
+	return BTRunning
+}
+
+func (n *BTInverter) Reset() { n.Child.Reset() }
+
+// BTSucceeder always succeeds once its child is done.
+type BTSucceeder struct {
+	Child BTNode
+}
+
+func (n *BTSucceeder) Tick(ctx *BTContext) BTStatus {
+	if n.Child.Tick(ctx) == BTRunning {
+		return BTRunning
+	}
+	return BTSuccess
+}
+
+func (n *BTSucceeder) Reset() { n.Child.Reset() }
+
+// BTRepeat runs its child Count times (or forever if Count is 0), failing if the child fails.
+type BTRepeat struct {
+	Child BTNode
+	Count int
+	done  int
+}
+
+func (n *BTRepeat) Tick(ctx *BTContext) BTStatus {
+
+	switch n.Child.Tick(ctx) {
+	case BTRunning:
+		return BTRunning
+	case BTFailure:
+		n.done = 0
+		return BTFailure
+	}
+
+	n.Child.Reset()
+	n.done++
+
+	if n.Count > 0 && n.done >= n.Count {
+		n.done = 0
+		return BTSuccess
+	}
+
+	return BTRunning
+
+}
+
+func (n *BTRepeat) Reset() {
+	n.done = 0
+	n.Child.Reset()
+}
+
+// BTCooldown fails without running its child until Frames have passed since the child last finished.
+type BTCooldown struct {
+	Child     BTNode
+	Frames    int
+	remaining int
+}
+
+func (n *BTCooldown) Tick(ctx *BTContext) BTStatus {
+
+	if n.remaining > 0 {
+		n.remaining--
+		return BTFailure
+	}
+
+	status := n.Child.Tick(ctx)
+
+	if status != BTRunning {
+		n.remaining = n.Frames
+	}
+
+	return status
+
+}
+
+// The cooldown keeps counting down across resets; that's the point of it.
+func (n *BTCooldown) Reset() { n.Child.Reset() }
+
+// LEAVES
+
+// BTWait runs for the given number of frames, then succeeds.
+type BTWait struct {
+	Frames  int
+	elapsed int
+}
+
+func (n *BTWait) Tick(ctx *BTContext) BTStatus {
+	n.elapsed++
+	if n.elapsed >= n.Frames {
+		n.elapsed = 0
+		return BTSuccess
+	}
+	return BTRunning
+}
+
+func (n *BTWait) Reset() { n.elapsed = 0 }
+
+// BTMoveTo walks to the position stored in the blackboard under Key, succeeding on arrival. It fails if there's no
+// position stored.
+type BTMoveTo struct {
+	Key    string
+	Margin float64
+}
+
+func (n *BTMoveTo) Tick(ctx *BTContext) BTStatus {
+
+	goal := ctx.Blackboard.Vector(n.Key)
+
+	if goal == nil {
+		return BTFailure
+	}
+
+	if ctx.Agent.AtPosition(goal, n.Margin) {
+		ctx.Agent.Stop()
+		return BTSuccess
+	}
+
+	ctx.Agent.MoveTo(goal)
+	return BTRunning
+
+}
+
+func (n *BTMoveTo) Reset() {}
+
+// BTShoot fires the agent's weapon at its target.
+type BTShoot struct{}
+
+func (n *BTShoot) Tick(ctx *BTContext) BTStatus {
+
+	target := ctx.Agent.TargetPosition()
+
+	if target == nil {
+		return BTFailure
+	}
+
+	ctx.Agent.Stop()
+
+	if ctx.Agent.Fire(target.Sub(ctx.Agent.Position()).Unit()) {
+		return BTSuccess
+	}
+
+	return BTFailure
+
+}
+
+func (n *BTShoot) Reset() {}
+
+type BTStop struct{}
+
+func (n *BTStop) Tick(ctx *BTContext) BTStatus {
+	ctx.Agent.Stop()
+	return BTSuccess
+}
+
+func (n *BTStop) Reset() {}
+
+type BTTargetVisible struct{}
+
+func (n *BTTargetVisible) Tick(ctx *BTContext) BTStatus {
+	if ctx.Agent.CanSeeTarget() {
+		return BTSuccess
+	}
+	return BTFailure
+}
+
+func (n *BTTargetVisible) Reset() {}
+
+type BTTargetCloserThan struct {
+	Distance float64
+}
+
+func (n *BTTargetCloserThan) Tick(ctx *BTContext) BTStatus {
+	if target := ctx.Agent.TargetPosition(); target != nil && target.Sub(ctx.Agent.Position()).Magnitude() < n.Distance {
+		return BTSuccess
+	}
+	return BTFailure
+}
+
+func (n *BTTargetCloserThan) Reset() {}
+
+// BTRememberTarget stores the target's position in the blackboard under Key.
+type BTRememberTarget struct {
+	Key string
+}
+
+func (n *BTRememberTarget) Tick(ctx *BTContext) BTStatus {
+	if target := ctx.Agent.TargetPosition(); target != nil {
+		ctx.Blackboard[n.Key] = target
+		return BTSuccess
+	}
+	return BTFailure
+}
+
+func (n *BTRememberTarget) Reset() {}
+
+// BTPickRandomPoint stores a random walkable position within Radius cells of the agent's home under Key, unless
+// there's already a position stored there.
+type BTPickRandomPoint struct {
+	Key    string
+	Radius int
+}
+
+func (n *BTPickRandomPoint) Tick(ctx *BTContext) BTStatus {
+	if ctx.Blackboard.Vector(n.Key) != nil {
+		return BTSuccess
+	}
+	if p := ctx.Agent.RandomWalkableNear(ctx.Agent.HomePosition(), n.Radius); p != nil {
+		ctx.Blackboard[n.Key] = p
+		return BTSuccess
+	}
+	return BTFailure
+}
+
+func (n *BTPickRandomPoint) Reset() {}
+
+type BTHasKey struct {
+	Key string
+}
+
+func (n *BTHasKey) Tick(ctx *BTContext) BTStatus {
+	if _, exists := ctx.Blackboard[n.Key]; exists {
+		return BTSuccess
+	}
+	return BTFailure
+}
+
+func (n *BTHasKey) Reset() {}
+
+type BTClearKey struct {
+	Key string
+}
+
+func (n *BTClearKey) Tick(ctx *BTContext) BTStatus {
+	delete(ctx.Blackboard, n.Key)
+	return BTSuccess
+}
+
+func (n *BTClearKey) Reset() {}
+
+// LOADING
+
+// BTSpec is a node as written in a behavior tree data file. Which of the fields matter depends on the Type.
+type BTSpec struct {
+	Type     string
+	Children []*BTSpec
+	Child    *BTSpec
+	Key      string
+	Frames   int
+	Count    int
+	Distance float64
+	Radius   int
+	Margin   float64
+}
+
+func LoadBTSpec(path string) (*BTSpec, error) {
+
+	data, err := ioutil.ReadFile(getPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &BTSpec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, err
+	}
+
+	// Build once to catch mistakes in the file early
+	if _, err := spec.Build(); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+
+}
+
+// Build creates a fresh set of nodes from the spec.
+func (spec *BTSpec) Build() (BTNode, error) {
+
+	children := []BTNode{}
+
+	for _, c := range spec.Children {
+		node, err := c.Build()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+
+	var child BTNode
+
+	if spec.Child != nil {
+		node, err := spec.Child.Build()
+		if err != nil {
+			return nil, err
+		}
+		child = node
+	}
+
+	decorator := func(node BTNode) (BTNode, error) {
+		if child == nil {
+			return nil, fmt.Errorf("behavior tree node %q needs a child", spec.Type)
+		}
+		return node, nil
+	}
+
+	switch spec.Type {
+	case "sequence":
+		return &BTSequence{Children: children}, nil
+	case "selector":
+		return &BTSelector{Children: children}, nil
+	case "inverter":
+		return decorator(&BTInverter{Child: child})
+	case "succeeder":
+		return decorator(&BTSucceeder{Child: child})
+	case "repeat":
+		return decorator(&BTRepeat{Child: child, Count: spec.Count})
+	case "cooldown":
+		return decorator(&BTCooldown{Child: child, Frames: spec.Frames})
+	case "wait":
+		return &BTWait{Frames: spec.Frames}, nil
+	case "moveTo":
+		margin := spec.Margin
+		if margin == 0 {
+			margin = 4
+		}
+		return &BTMoveTo{Key: spec.Key, Margin: margin}, nil
+	case "shoot":
+		return &BTShoot{}, nil
+	case "stop":
+		return &BTStop{}, nil
+	case "targetVisible":
+		return &BTTargetVisible{}, nil
+	case "targetCloserThan":
+		return &BTTargetCloserThan{Distance: spec.Distance}, nil
+	case "rememberTarget":
+		return &BTRememberTarget{Key: spec.Key}, nil
+	case "pickRandomPoint":
+		return &BTPickRandomPoint{Key: spec.Key, Radius: spec.Radius}, nil
+	case "hasKey":
+		return &BTHasKey{Key: spec.Key}, nil
+	case "clearKey":
+		return &BTClearKey{Key: spec.Key}, nil
+	}
+
+	return nil, fmt.Errorf("unknown behavior tree node type %q", spec.Type)
+
+}
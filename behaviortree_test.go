@@ -0,0 +1,314 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kvartborg/vector"
+)
+
+// stubAgent stands in for an NPC, so trees can be ticked without a Level or renderer.
+type stubAgent struct {
+	pos, home, target vector.Vector
+	canSee            bool
+	goal              vector.Vector // Where the agent was last told to move, or nil if it's stopped
+	shots             int
+}
+
+func (a *stubAgent) Position() vector.Vector     { return a.pos.Clone() }
+func (a *stubAgent) HomePosition() vector.Vector { return a.home.Clone() }
+func (a *stubAgent) MoveTo(goal vector.Vector)   { a.goal = goal.Clone() }
+func (a *stubAgent) Stop()                       { a.goal = nil }
+func (a *stubAgent) CanSeeTarget() bool          { return a.canSee }
+
+func (a *stubAgent) AtPosition(pos vector.Vector, margin float64) bool {
+	return vector.Sub(pos, a.pos).Magnitude() <= margin
+}
+
+func (a *stubAgent) Fire(direction vector.Vector) bool {
+	a.shots++
+	return true
+}
+
+func (a *stubAgent) TargetPosition() vector.Vector {
+	if a.canSee {
+		return a.target.Clone()
+	}
+	return nil
+}
+
+func (a *stubAgent) RandomWalkableNear(pos vector.Vector, radius int) vector.Vector {
+	return vector.Vector{pos[0] + 16, pos[1]}
+}
+
+// statusNode always returns the same status, counting how many times it's ticked.
+type statusNode struct {
+	Status BTStatus
+	ticks  int
+}
+
+func (n *statusNode) Tick(ctx *BTContext) BTStatus {
+	n.ticks++
+	return n.Status
+}
+
+func (n *statusNode) Reset() {}
+
+func newTestContext(agent BTAgent) *BTContext {
+	return &BTContext{Agent: agent, Blackboard: Blackboard{}}
+}
+
+func TestCompositesShortCircuit(t *testing.T) {
+
+	sequence := func(children []BTNode) BTNode { return &BTSequence{Children: children} }
+	selector := func(children []BTNode) BTNode { return &BTSelector{Children: children} }
+
+	tests := []struct {
+		name      string
+		composite func([]BTNode) BTNode
+		children  []BTStatus
+		want      BTStatus
+		ticks     []int
+	}{
+		{"sequence all succeed", sequence, []BTStatus{BTSuccess, BTSuccess, BTSuccess}, BTSuccess, []int{1, 1, 1}},
+		{"sequence stops at failure", sequence, []BTStatus{BTSuccess, BTFailure, BTSuccess}, BTFailure, []int{1, 1, 0}},
+		{"sequence stops at running", sequence, []BTStatus{BTRunning, BTSuccess, BTSuccess}, BTRunning, []int{1, 0, 0}},
+		{"selector all fail", selector, []BTStatus{BTFailure, BTFailure, BTFailure}, BTFailure, []int{1, 1, 1}},
+		{"selector stops at success", selector, []BTStatus{BTFailure, BTSuccess, BTFailure}, BTSuccess, []int{1, 1, 0}},
+		{"selector stops at running", selector, []BTStatus{BTFailure, BTRunning, BTSuccess}, BTRunning, []int{1, 1, 0}},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			nodes := []*statusNode{}
+			children := []BTNode{}
+			for _, status := range test.children {
+				node := &statusNode{Status: status}
+				nodes = append(nodes, node)
+				children = append(children, node)
+			}
+
+			if got := test.composite(children).Tick(newTestContext(&stubAgent{})); got != test.want {
+				t.Errorf("got status %d, want %d", got, test.want)
+			}
+
+			for i, node := range nodes {
+				if node.ticks != test.ticks[i] {
+					t.Errorf("child %d ticked %d times, want %d", i, node.ticks, test.ticks[i])
+				}
+			}
+
+		})
+
+	}
+
+}
+
+func TestWait(t *testing.T) {
+
+	wait := &BTWait{Frames: 3}
+	ctx := newTestContext(&stubAgent{})
+
+	// Finishes on its third tick, and starts over after that.
+	want := []BTStatus{BTRunning, BTRunning, BTSuccess, BTRunning, BTRunning, BTSuccess}
+
+	for i, status := range want {
+		if got := wait.Tick(ctx); got != status {
+			t.Errorf("tick %d: got status %d, want %d", i+1, got, status)
+		}
+	}
+
+	wait.Tick(ctx)
+	wait.Reset()
+	for i := 0; i < 2; i++ {
+		if got := wait.Tick(ctx); got != BTRunning {
+			t.Errorf("tick %d after reset: got status %d, want it still running", i+1, got)
+		}
+	}
+
+}
+
+func TestCooldown(t *testing.T) {
+
+	child := &statusNode{Status: BTSuccess}
+	cooldown := &BTCooldown{Child: child, Frames: 2}
+	ctx := newTestContext(&stubAgent{})
+
+	// Fails for the two frames after its child finishes, without ticking it.
+	want := []BTStatus{BTSuccess, BTFailure, BTFailure, BTSuccess, BTFailure}
+
+	for i, status := range want {
+		if got := cooldown.Tick(ctx); got != status {
+			t.Errorf("tick %d: got status %d, want %d", i+1, got, status)
+		}
+	}
+
+	if child.ticks != 2 {
+		t.Errorf("child ticked %d times, want 2", child.ticks)
+	}
+
+	// Resetting the tree doesn't cut the cooldown short.
+	cooldown.Reset()
+	if got := cooldown.Tick(ctx); got != BTFailure {
+		t.Errorf("after reset: got status %d, want it still cooling down", got)
+	}
+
+}
+
+func TestBlackboard(t *testing.T) {
+
+	agent := &stubAgent{pos: vector.Vector{0, 0}, target: vector.Vector{32, 0}, canSee: true}
+	ctx := newTestContext(agent)
+
+	if got := (&BTHasKey{Key: "spot"}).Tick(ctx); got != BTFailure {
+		t.Errorf("hasKey on an empty blackboard: got status %d, want failure", got)
+	}
+
+	if got := (&BTMoveTo{Key: "spot", Margin: 4}).Tick(ctx); got != BTFailure {
+		t.Errorf("moveTo without a position stored: got status %d, want failure", got)
+	}
+
+	if got := (&BTRememberTarget{Key: "spot"}).Tick(ctx); got != BTSuccess {
+		t.Fatalf("rememberTarget: got status %d, want success", got)
+	}
+
+	if spot := ctx.Blackboard.Vector("spot"); spot == nil || !spot.Equal(agent.target) {
+		t.Fatalf("rememberTarget stored %v, want %v", spot, agent.target)
+	}
+
+	if got := (&BTHasKey{Key: "spot"}).Tick(ctx); got != BTSuccess {
+		t.Errorf("hasKey after rememberTarget: got status %d, want success", got)
+	}
+
+	// The target moving doesn't change what was remembered.
+	agent.target = vector.Vector{64, 64}
+
+	moveTo := &BTMoveTo{Key: "spot", Margin: 4}
+
+	if got := moveTo.Tick(ctx); got != BTRunning {
+		t.Errorf("moveTo: got status %d, want running", got)
+	}
+
+	if agent.goal == nil || !agent.goal.Equal(vector.Vector{32, 0}) {
+		t.Errorf("moveTo sent the agent to %v, want [32 0]", agent.goal)
+	}
+
+	agent.pos = vector.Vector{30, 0}
+
+	if got := moveTo.Tick(ctx); got != BTSuccess || agent.goal != nil {
+		t.Errorf("moveTo on arrival: got status %d and goal %v, want success and stopped", got, agent.goal)
+	}
+
+	// Picking a point keeps the one already stored.
+	if got := (&BTPickRandomPoint{Key: "spot", Radius: 4}).Tick(ctx); got != BTSuccess || !ctx.Blackboard.Vector("spot").Equal(vector.Vector{32, 0}) {
+		t.Errorf("pickRandomPoint replaced the stored position with %v", ctx.Blackboard.Vector("spot"))
+	}
+
+	(&BTClearKey{Key: "spot"}).Tick(ctx)
+
+	if got := (&BTHasKey{Key: "spot"}).Tick(ctx); got != BTFailure {
+		t.Errorf("hasKey after clearKey: got status %d, want failure", got)
+	}
+
+	ctx.Blackboard["spot"] = "not a position"
+	if spot := ctx.Blackboard.Vector("spot"); spot != nil {
+		t.Errorf("Vector returned %v for a value that isn't a position", spot)
+	}
+
+}
+
+func TestLoadHunterTree(t *testing.T) {
+
+	spec, err := LoadBTSpec("assets/hunter_tree.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newHunter := func() *BehaviorTree {
+		root, err := spec.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewBehaviorTree(root)
+	}
+
+	t.Run("shoots when close, holding still while cooling down", func(t *testing.T) {
+
+		tree := newHunter()
+		agent := &stubAgent{pos: vector.Vector{0, 0}, target: vector.Vector{64, 0}, canSee: true}
+
+		for i := 0; i < 41; i++ {
+			tree.Tick(agent)
+			if agent.goal != nil {
+				t.Fatalf("tick %d: hunter moved towards %v while in range", i+1, agent.goal)
+			}
+		}
+
+		if agent.shots != 1 {
+			t.Errorf("shot %d times in the first 41 ticks, want 1", agent.shots)
+		}
+
+		tree.Tick(agent)
+
+		if agent.shots != 2 {
+			t.Errorf("shot %d times once the cooldown was over, want 2", agent.shots)
+		}
+
+	})
+
+	t.Run("chases a target further away", func(t *testing.T) {
+
+		tree := newHunter()
+		agent := &stubAgent{pos: vector.Vector{0, 0}, target: vector.Vector{160, 0}, canSee: true}
+
+		tree.Tick(agent)
+
+		if agent.shots != 0 || agent.goal == nil || !agent.goal.Equal(agent.target) {
+			t.Errorf("got %d shots and goal %v, want no shots and the target's position", agent.shots, agent.goal)
+		}
+
+		// Once it's out of sight, the hunter heads for where it last saw the target.
+		agent.canSee = false
+		agent.target = vector.Vector{300, 300}
+		tree.Tick(agent)
+
+		if agent.goal == nil || !agent.goal.Equal(vector.Vector{160, 0}) {
+			t.Errorf("lost the target and went for %v, want [160 0]", agent.goal)
+		}
+
+	})
+
+	t.Run("wanders without a target", func(t *testing.T) {
+
+		tree := newHunter()
+		agent := &stubAgent{pos: vector.Vector{0, 0}, home: vector.Vector{0, 0}}
+
+		tree.Tick(agent)
+
+		if agent.goal == nil || !agent.goal.Equal(vector.Vector{16, 0}) {
+			t.Errorf("wandered towards %v, want [16 0]", agent.goal)
+		}
+
+	})
+
+}
+
+func TestBuildErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		spec *BTSpec
+	}{
+		{"unknown type", &BTSpec{Type: "dance"}},
+		{"decorator without a child", &BTSpec{Type: "cooldown", Frames: 10}},
+		{"bad node deep down", &BTSpec{Type: "selector", Children: []*BTSpec{{Type: "stop"}, {Type: "sequence", Children: []*BTSpec{{Type: "dance"}}}}}},
+	}
+
+	for _, test := range tests {
+		if _, err := test.spec.Build(); err == nil {
+			t.Errorf("%s: built without an error", test.name)
+		}
+	}
+
+}
@@ -6,219 +6,310 @@ import (
 
 	"github.com/SolarLune/paths"
 	"github.com/hajimehoshi/ebiten"
-	"github.com/hajimehoshi/ebiten/ebitenutil"
 	"github.com/kvartborg/vector"
 )
 
 const TypeAIControlComponent = "AIControl"
 
 type AIControlComponent struct {
-	GameObject             *GameObject
-	Facing                 vector.Vector
-	TargetPos              vector.Vector
-	Path                   *paths.Path
-	PathRecalculationTimer int
-	TargetSpeed            vector.Vector
+	GameObject     *GameObject
+	Facing         vector.Vector
+	TargetPos      vector.Vector
+	Path           *paths.Path
+	RepathDistance float64 // How far the goal can move before the path is recalculated
+	UseFlowField   bool    // Steer by the Level's FlowField instead of a path when heading for the player
+	TargetSpeed    vector.Vector
+	Behavior       *AIBehavior
+	Tree           *BehaviorTree // If set, the tree drives the NPC instead of the Behavior
+	State          *AIState
+	StateTime      int
+	Target         *GameObject
+	Perception     *Perception
+	MoveDirection  vector.Vector // Set by the current state each frame; the body accelerates this way
+	Steering       *Steering
+	Home           vector.Vector
+	Goal           vector.Vector
+	PatrolPoints   []vector.Vector
+	PatrolIndex    int
 }
 
-func NewAIControlComponent() *AIControlComponent {
+func NewAIControlComponent(behavior *AIBehavior) *AIControlComponent {
 	return &AIControlComponent{
-		Facing:    vector.Vector{0, 1},
-		TargetPos: vector.Vector{0, 0},
+		Facing:         vector.Vector{0, 1},
+		TargetPos:      vector.Vector{0, 0},
+		Behavior:       behavior,
+		MoveDirection:  vector.Vector{0, 0},
+		Perception:     NewPerception(),
+		Steering:       NewSteering(),
+		RepathDistance: 48,
 	}
 }
 
+func NewTreeAIControlComponent(tree *BehaviorTree) *AIControlComponent {
+	ai := NewAIControlComponent(nil)
+	ai.Tree = tree
+	return ai
+}
+
 func (ai *AIControlComponent) OnAdd(g *GameObject) { ai.GameObject = g }
 
-func (ai *AIControlComponent) OnRemove(g *GameObject) {}
+func (ai *AIControlComponent) OnRemove(g *GameObject) {
+	g.Level.PathScheduler.Cancel(ai)
+}
 
 func (ai *AIControlComponent) Update(screen *ebiten.Image) {
 
 	if b := ai.GameObject.GetComponent(TypeBodyComponent); b != nil {
 
 		body := b.(*BodyComponent)
-		bodyCenterX, bodyCenterY := body.Object.Center()
-		bodyPosition := vector.Vector{bodyCenterX, bodyCenterY}
 
-		if ai.PathRecalculationTimer >= 3000 {
-			ai.PathRecalculationTimer = 0
-			ai.RecalculatePath()
+		if ai.Home == nil {
+			ai.Home = body.Center()
 		}
-		ai.PathRecalculationTimer++
 
-		if ai.Path != nil {
+		ai.Perception.Sense(ai)
 
-			targetCell := ai.Path.Current()
+		if ai.Tree != nil {
 
-			// DEBUG
-			if ai.GameObject.Level.Game.DebugMode {
+			ai.Tree.Tick(ai)
 
-				for _, cell := range ai.Path.Cells {
+		} else if ai.Behavior != nil {
 
-					cx := float64(cell.X * 16)
-					cy := float64(cell.Y * 16)
-					cellColor := color.RGBA{0, 255, 0, 192}
-					if ai.Path.Next() == cell {
-						cellColor = color.RGBA{0, 0, 255, 192}
-					}
-					ebitenutil.DrawRect(screen, cx-ai.GameObject.Level.CameraOffsetX, cy-ai.GameObject.Level.CameraOffsetY, 16, 16, cellColor)
+			if ai.State == nil {
+				ai.SetState(ai.Behavior.Start)
+			}
 
+			if ai.State != nil {
+
+				if next := ai.Behavior.NextState(ai); next != "" {
+					ai.SetState(next)
+				}
+
+				if ai.State.OnUpdate != nil {
+					ai.State.OnUpdate(ai)
 				}
+				ai.StateTime++
 
 			}
 
-			if targetCell != nil {
+		}
 
-				space := ai.GameObject.Level.Space
+		// DEBUG
+		if ai.GameObject.Level.Game.DebugMode {
+			ai.Perception.DrawDebug(screen, ai)
+		}
 
-				nextX := float64((targetCell.X * space.CellWidth) + (space.CellWidth / 2))
-				nextY := float64((targetCell.Y * space.CellHeight) + (space.CellHeight / 2))
+		if ai.Path != nil && ai.GameObject.Level.Game.DebugMode {
 
-				dx := nextX - bodyPosition[0]
-				dy := nextY - bodyPosition[1]
+			for _, cell := range ai.Path.Cells {
 
-				dv := vector.Vector{float64(dx), float64(dy)}
+				cx := float64(cell.X * 16)
+				cy := float64(cell.Y * 16)
+				cellColor := color.RGBA{0, 255, 0, 192}
+				if ai.Path.Next() == cell {
+					cellColor = color.RGBA{0, 0, 255, 192}
+				}
+				ai.GameObject.Level.Camera.DrawRect(screen, cx, cy, 16, 16, cellColor)
 
-				if dv.Magnitude() <= 4 {
+			}
 
-					if !ai.Path.AtEnd() {
+		}
 
-						ai.Path.Advance()
+		body.Thrust = ai.Steering.Steer(ai)
 
-						for i := len(ai.Path.Cells) - 1; i > 0; i-- {
-							end := ai.Path.Cells[i]
-							start := ai.Path.Cells[ai.Path.CurrentIndex]
+		if body.Thrust.Magnitude() > 0 && body.Speed.Magnitude() > 0 {
+			ai.Facing = body.Speed.Clone().Unit()
+		}
 
-							if start == end {
-								break
-							}
+	}
 
-							free := true
+}
 
-							for _, c := range space.CellsInLine(start.X, start.Y, end.X, end.Y) {
-								if c.ContainsTags("solid") {
-									free = false
-									break
-								}
-							}
+// SetState moves the NPC into the named state of its Behavior. If the Behavior doesn't have that state, the NPC stays
+// in the one it's in.
+func (ai *AIControlComponent) SetState(name string) {
 
-							if free {
-								ai.Path.SetIndex(i - 1)
-								body.Speed = vector.Vector{0, 0}
+	state, exists := ai.Behavior.States[name]
+	if !exists {
+		return
+	}
 
-								targetCell = ai.Path.Next()
-								nextX = float64((targetCell.X * space.CellWidth) + (space.CellWidth / 2))
-								nextY = float64((targetCell.Y * space.CellHeight) + (space.CellHeight / 2))
+	if ai.State != nil && ai.State.OnExit != nil {
+		ai.State.OnExit(ai)
+	}
 
-								dx = nextX - bodyPosition[0]
-								dy = nextY - bodyPosition[1]
+	ai.State = state
+	ai.StateTime = 0
+	ai.Path = nil
+	ai.Goal = nil
+	ai.Stop()
 
-								dv = vector.Vector{float64(dx), float64(dy)}
+	if ai.State.OnEnter != nil {
+		ai.State.OnEnter(ai)
+	}
 
-								break
-							}
+}
 
-						}
+// MoveTo has the NPC follow a path towards the goal position. A new path is requested from the Level's PathScheduler
+// when the NPC has none, when the goal moves into another cell or further than RepathDistance away from where the
+// current path leads, or when the NPC's reached the end of its path and the goal's moved at all; until the new path
+// comes in, the NPC keeps following the old one.
+func (ai *AIControlComponent) MoveTo(goal vector.Vector) {
 
-					} else {
-						ai.RecalculatePath()
-					}
+	if ai.UseFlowField && ai.FlowTo(goal) {
+		return
+	}
 
-				}
+	if ai.Path == nil || ai.GoalMoved(goal) || (ai.Path.AtEnd() && !goal.Equal(ai.TargetPos)) {
+		ai.RequestPath(goal)
+	}
+
+	if ai.Path == nil || ai.Path.Current() == nil {
+		ai.Stop()
+		return
+	}
 
-				friction := 0.25
-				maxSpeed := 2.0
-				accel := 0.25 + friction
+	body := ai.GameObject.GetComponent(TypeBodyComponent).(*BodyComponent)
+	bodyPosition := body.Center()
 
-				if body.Speed[0] > friction {
-					body.Speed[0] -= friction
-				} else if body.Speed[0] < -friction {
-					body.Speed[0] += friction
-				} else {
-					body.Speed[0] = 0
-				}
+	space := ai.GameObject.Level.Space
+	targetCell := ai.Path.Current()
 
-				if body.Speed[1] > friction {
-					body.Speed[1] -= friction
-				} else if body.Speed[1] < -friction {
-					body.Speed[1] += friction
-				} else {
-					body.Speed[1] = 0
-				}
+	nextX := float64((targetCell.X * space.CellWidth) + (space.CellWidth / 2))
+	nextY := float64((targetCell.Y * space.CellHeight) + (space.CellHeight / 2))
 
-				body.Speed.Add(dv.Unit().Scale(accel))
+	dx := nextX - bodyPosition[0]
+	dy := nextY - bodyPosition[1]
 
-				if body.Speed.Magnitude() > maxSpeed {
-					body.Speed.Unit().Scale(maxSpeed)
+	dv := vector.Vector{float64(dx), float64(dy)}
+	next := vector.Vector{nextX, nextY}
+
+	if dv.Magnitude() <= 4 {
+
+		if !ai.Path.AtEnd() {
+
+			ai.Path.Advance()
+
+			// Shortcuts only go over plain walkable floor, so they don't cut across pits or wade through water and
+			// doors the path went around
+			grid := ai.GameObject.Level.PathfindingGrid
+			blocked := func(x, y int) bool {
+				cell := grid.Get(x, y)
+				return cell == nil || !cell.Walkable || cell.Cost > 1
+			}
+
+			for i := len(ai.Path.Cells) - 1; i > 0; i-- {
+				end := ai.Path.Cells[i]
+				start := ai.Path.Cells[ai.Path.CurrentIndex]
+
+				if start == end {
+					break
 				}
 
-				ai.Facing = body.Speed.Clone().Unit()
+				if ai.GameObject.Level.ClearLine(start.X, start.Y, end.X, end.Y, blocked) {
+					ai.Path.SetIndex(i - 1)
+					body.Speed = vector.Vector{0, 0}
+
+					targetCell = ai.Path.Next()
+					nextX = float64((targetCell.X * space.CellWidth) + (space.CellWidth / 2))
+					nextY = float64((targetCell.Y * space.CellHeight) + (space.CellHeight / 2))
+
+					next = vector.Vector{nextX, nextY}
+
+					break
+				}
 
-			} else {
-				ai.RecalculatePath()
 			}
 
-		} else {
-			ai.RecalculatePath()
 		}
 
-		if a := ai.GameObject.GetComponent(TypeAnimationComponent); a != nil {
-			anim := a.(*AnimationComponent)
+	}
 
-			xc := 0
-			yc := 0
+	if ai.Path.AtEnd() {
+		ai.MoveDirection = Arrive(bodyPosition, next, ai.Steering.ArriveRadius)
+	} else {
+		ai.MoveDirection = Seek(bodyPosition, next)
+	}
 
-			min := 0.3
+}
 
-			if math.Abs(body.Speed[0]) > min {
-				xc = 1
-			}
+// FlowTo steers the NPC towards the goal using the Level's FlowField. It returns false if the FlowField doesn't lead
+// to the goal's cell (or doesn't reach the NPC), in which case the NPC should find its own way there.
+func (ai *AIControlComponent) FlowTo(goal vector.Vector) bool {
 
-			if body.Speed[1] < -min {
-				yc = -1
-			} else if body.Speed[1] > min {
-				yc = 1
-			}
+	level := ai.GameObject.Level
+	ff := level.FlowField
 
-			if yc < 0 && xc != 0 {
-				anim.Ase.Play("ur")
-			} else if yc < 0 && xc == 0 {
-				anim.Ase.Play("u")
-			} else if yc == 0 && xc != 0 {
-				anim.Ase.Play("r")
-			} else if yc > 0 && xc != 0 {
-				anim.Ase.Play("dr")
-			} else if yc > 0 && xc == 0 {
-				anim.Ase.Play("d")
-			}
+	if ff == nil {
+		return false
+	}
 
-			if body.Speed.Magnitude() == 0 {
-				anim.Ase.PlaySpeed = 0
-				if anim.Ase.CurrentAnimation != nil {
-					anim.Ase.CurrentFrame = anim.Ase.CurrentAnimation.Start
-				}
-			} else {
-				anim.Ase.PlaySpeed = 1
-			}
+	if gx, gy := level.CellAt(goal[0], goal[1]); gx != ff.TargetX || gy != ff.TargetY {
+		return false
+	}
 
-			d := ai.GameObject.GetComponent(TypeDrawComponent)
+	pos := ai.Position()
+	dx, dy := level.CellAt(pos[0], pos[1])
 
-			if d != nil {
+	if math.IsInf(ff.DistanceAt(dx, dy), 1) {
+		return false
+	}
 
-				DrawComponent := d.(*DrawComponent)
+	ai.Path = nil
 
-				if ai.Facing[0] < 0 {
-					DrawComponent.FlipHorizontal = true
-				} else if ai.Facing[0] > 0 {
-					DrawComponent.FlipHorizontal = false
-				}
+	if dir := ff.Direction(pos); dir != nil {
+		ai.MoveDirection = dir
+	} else {
+		ai.MoveDirection = Arrive(pos, goal, ai.Steering.ArriveRadius)
+	}
 
-			}
+	return true
 
-		}
+}
+
+func (ai *AIControlComponent) Stop() {
+	ai.MoveDirection = vector.Vector{0, 0}
+}
+
+// Fire shoots the NPC's weapon (if it has one) in the given direction, returning true if it fired.
+func (ai *AIControlComponent) Fire(direction vector.Vector) bool {
+
+	ai.Facing = direction.Clone()
+
+	if wc := ai.GameObject.GetComponent(TypeWeaponComponent); wc != nil {
+		weapon := wc.(*WeaponComponent)
+		weapon.FireDirection = direction.Clone()
+		return weapon.Fire()
+	}
+
+	return false
+
+}
 
+func (ai *AIControlComponent) HomePosition() vector.Vector {
+	return ai.Home
+}
+
+// GoalMoved returns if the goal's moved into a different cell from the position the NPC's path was last requested
+// for, or further than RepathDistance from it. Requests from a goal that keeps moving are spread out and deduplicated
+// by the Level's PathScheduler.
+func (ai *AIControlComponent) GoalMoved(goal vector.Vector) bool {
+
+	if vector.Sub(goal, ai.TargetPos).Magnitude() > ai.RepathDistance {
+		return true
 	}
 
+	level := ai.GameObject.Level
+	gx, gy := level.CellAt(goal[0], goal[1])
+	tx, ty := level.CellAt(ai.TargetPos[0], ai.TargetPos[1])
+	return gx != tx || gy != ty
+
+}
+
+// RequestPath queues the NPC to have its path recalculated towards the goal.
+func (ai *AIControlComponent) RequestPath(goal vector.Vector) {
+	ai.TargetPos = goal.Clone()
+	ai.GameObject.Level.PathScheduler.Request(ai)
 }
 
 func (ai *AIControlComponent) RecalculatePath() {
@@ -230,17 +321,44 @@ func (ai *AIControlComponent) RecalculatePath() {
 		bodyPosition := vector.Vector{bodyCenterX, bodyCenterY}
 		grid := ai.GameObject.Level.PathfindingGrid
 
-		target := ai.GameObject.Level.GetGameObjectByComponent(TypePlayerControlComponent)
+		ai.Path = grid.GetPath(bodyPosition[0], bodyPosition[1], ai.TargetPos[0], ai.TargetPos[1], false)
 
-		if len(target) > 0 {
-			targetBody := target[0].GetComponent(TypeBodyComponent).(*BodyComponent)
-			ai.TargetPos = targetBody.Center()
-		}
+	}
 
-		ai.Path = grid.GetPath(bodyPosition[0], bodyPosition[1], ai.TargetPos[0], ai.TargetPos[1], false)
+}
 
+func (ai *AIControlComponent) Position() vector.Vector {
+	return ai.GameObject.GetComponent(TypeBodyComponent).(*BodyComponent).Center()
+}
+
+func (ai *AIControlComponent) AtPosition(pos vector.Vector, margin float64) bool {
+	return vector.Sub(pos, ai.Position()).Magnitude() <= margin
+}
+
+// TargetPosition returns the center of the target's body if the NPC can see it, or where it last saw it if it can't.
+// It returns nil if the NPC doesn't know of any target.
+func (ai *AIControlComponent) TargetPosition() vector.Vector {
+	if ai.Target != nil && ai.Perception.CanSee {
+		if b := ai.Target.GetComponent(TypeBodyComponent); b != nil {
+			return b.(*BodyComponent).Center()
+		}
 	}
+	if ai.Perception.LastKnownPosition != nil {
+		return ai.Perception.LastKnownPosition.Clone()
+	}
+	return nil
+}
+
+// TargetDistance returns the distance to the target, or +Inf if there's no target.
+func (ai *AIControlComponent) TargetDistance() float64 {
+	if target := ai.TargetPosition(); target != nil {
+		return target.Sub(ai.Position()).Magnitude()
+	}
+	return math.Inf(1)
+}
 
+func (ai *AIControlComponent) CanSeeTarget() bool {
+	return ai.Perception.CanSee
 }
 
 func (ai *AIControlComponent) Type() string { return TypeAIControlComponent }
@@ -9,34 +9,202 @@ import (
 
 const TypeAnimationComponent = "Animation"
 
+const (
+	AnimEventFrame = iota // Fires when the animation reaches a frame
+	AnimEventStart        // Fires when the animation starts playing, or loops back around
+	AnimEventEnd          // Fires when the animation plays its last frame out
+)
+
+// AnimationEvent calls its Callback when the animation playing is its Tag (or any animation, if Tag is blank). Frame
+// is relative to the start of the tag, and only matters for AnimEventFrame events; -1 matches every frame.
+type AnimationEvent struct {
+	Kind     int
+	Tag      string
+	Frame    int32
+	Callback func(*AnimationComponent)
+}
+
+type queuedAnimation struct {
+	Tag  string
+	Once bool
+}
+
 type AnimationComponent struct {
-	AnimPath  string
-	Image     *ebiten.Image
-	Ase       *goaseprite.File
-	OnAnimEnd func(*AnimationComponent)
+	GameObject *GameObject
+	AnimPath   string
+	Image      *ebiten.Image
+	Ase        *goaseprite.File
+	OnAnimEnd  func(*AnimationComponent)
+	Events     []AnimationEvent
+	OneShot    bool // If the current animation holds its last frame when it ends instead of looping
+	Slices     map[string]*Slice
+	queue      []queuedAnimation
+	prevTag    string
+	prevFrame  int32
+	frameTime  float32 // How long the current frame's been up, counted the same way the File counts it
 }
 
 func NewAnimationComponent(animPath string) *AnimationComponent {
 	a := &AnimationComponent{AnimPath: animPath}
 	a.Ase = goaseprite.Open(a.AnimPath)
 	a.Image = GetImage(filepath.Join(filepath.Dir(a.AnimPath), a.Ase.ImagePath))
+	a.Slices = GetSlices(a.AnimPath)
 	return a
 }
 
-func (a *AnimationComponent) OnAdd(g *GameObject) {}
+func (a *AnimationComponent) OnAdd(g *GameObject) { a.GameObject = g }
 
 func (a *AnimationComponent) OnRemove(g *GameObject) {}
 
 func (a *AnimationComponent) Update(screen *ebiten.Image) {
 
+	frameBefore := a.Ase.CurrentFrame
+	delta := float32(1.0/60.0) * a.Ase.PlaySpeed
+
 	a.Ase.Update(1.0 / 60.0)
 
-	if a.Ase.FinishedAnimation && a.OnAnimEnd != nil {
-		a.OnAnimEnd(a)
+	anim := a.Ase.CurrentAnimation
+	if anim == nil {
+		return
+	}
+
+	// The File moves on from a frame once it's been up for longer than the frame's duration. A one-frame tag moves on
+	// to the same frame it was on, so the time's kept here to tell when playback steps (and so when it wraps around),
+	// rather than going by the frame changing.
+	a.frameTime += delta
+	stepped := a.frameTime > a.Ase.Frames[frameBefore].Duration
+	if stepped {
+		a.frameTime = 0
+	}
+
+	finished := a.Ase.FinishedAnimation && stepped
+
+	if finished && a.OneShot {
+		a.Ase.CurrentFrame = anim.End
+		if anim.Direction == goaseprite.PlayBackward {
+			a.Ase.CurrentFrame = anim.Start
+		}
+		a.Ase.PlaySpeed = 0
+	}
+
+	if anim.Name != a.prevTag || (finished && !a.OneShot) {
+		a.fire(AnimEventStart, anim.Name, 0)
+	}
+
+	if anim.Name != a.prevTag || a.Ase.CurrentFrame != a.prevFrame || (finished && !a.OneShot) {
+		a.fire(AnimEventFrame, anim.Name, a.Ase.CurrentFrame-anim.Start)
+	}
+
+	a.prevTag = anim.Name
+	a.prevFrame = a.Ase.CurrentFrame
+
+	a.applySlices()
+
+	if finished {
+
+		a.fire(AnimEventEnd, anim.Name, 0)
+
+		if a.OnAnimEnd != nil {
+			a.OnAnimEnd(a)
+		}
+
+		if len(a.queue) > 0 {
+			next := a.queue[0]
+			a.queue = a.queue[1:]
+			a.play(next.Tag, next.Once)
+		}
+
 	}
 
 }
 
-func (a *AnimationComponent) Type() string { return TypeAnimationComponent }
+func (a *AnimationComponent) fire(kind int, tag string, frame int32) {
+	for _, e := range a.Events {
+		if e.Kind == kind && (e.Tag == "" || e.Tag == tag) && (kind != AnimEventFrame || e.Frame < 0 || e.Frame == frame) {
+			e.Callback(a)
+		}
+	}
+}
+
+// OnFrame calls the callback whenever the tag's animation reaches the frame (counted from the start of the tag).
+func (a *AnimationComponent) OnFrame(tag string, frame int32, callback func(*AnimationComponent)) {
+	a.Events = append(a.Events, AnimationEvent{Kind: AnimEventFrame, Tag: tag, Frame: frame, Callback: callback})
+}
 
-//
+// OnTagStart calls the callback whenever the tag's animation starts playing or loops.
+func (a *AnimationComponent) OnTagStart(tag string, callback func(*AnimationComponent)) {
+	a.Events = append(a.Events, AnimationEvent{Kind: AnimEventStart, Tag: tag, Callback: callback})
+}
+
+// OnTagEnd calls the callback whenever the tag's animation plays its last frame out.
+func (a *AnimationComponent) OnTagEnd(tag string, callback func(*AnimationComponent)) {
+	a.Events = append(a.Events, AnimationEvent{Kind: AnimEventEnd, Tag: tag, Callback: callback})
+}
+
+// Play loops the tag's animation, dropping anything queued. If it's already playing, it carries on from where it is.
+func (a *AnimationComponent) Play(tag string) {
+	a.queue = nil
+	a.play(tag, false)
+}
+
+// PlayOnce plays the tag's animation from the start, holding its last frame once it's done (or moving on to whatever
+// was queued after it).
+func (a *AnimationComponent) PlayOnce(tag string) {
+	a.queue = nil
+	a.Ase.CurrentAnimation = nil
+	a.play(tag, true)
+}
+
+// Queue plays the tag's animation once the current one ends, after anything queued before it.
+func (a *AnimationComponent) Queue(tag string, once bool) {
+	if a.Ase.CurrentAnimation == nil {
+		a.play(tag, once)
+		return
+	}
+	a.queue = append(a.queue, queuedAnimation{Tag: tag, Once: once})
+}
+
+// Transition switches to looping the tag's animation without restarting it, picking up at the same frame (counted
+// from the start of the tag) the current animation was on. It's for swapping between variations of an animation,
+// like a walk cycle's directions.
+func (a *AnimationComponent) Transition(tag string) {
+
+	current := a.Ase.CurrentAnimation
+
+	if !a.Ase.HasAnimation(tag) {
+		return
+	}
+
+	if current == nil || current.Name == tag || a.OneShot {
+		a.Play(tag)
+		return
+	}
+
+	offset := a.Ase.CurrentFrame - current.Start
+
+	a.Play(tag)
+
+	next := a.Ase.CurrentAnimation
+	if offset > next.End-next.Start {
+		offset = next.End - next.Start
+	}
+	a.Ase.CurrentFrame = next.Start + offset
+
+	// Same frame of the same cycle, so it's not a new start.
+	a.prevTag = next.Name
+	a.prevFrame = a.Ase.CurrentFrame
+
+}
+
+// Busy returns if a one-shot animation is still playing out, or there's something queued.
+func (a *AnimationComponent) Busy() bool {
+	return len(a.queue) > 0 || (a.OneShot && a.Ase.PlaySpeed != 0)
+}
+
+func (a *AnimationComponent) play(tag string, once bool) {
+	a.OneShot = once
+	a.Ase.Play(tag)
+	a.Ase.PlaySpeed = 1
+}
+
+func (a *AnimationComponent) Type() string { return TypeAnimationComponent }
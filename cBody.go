@@ -6,7 +6,6 @@ import (
 
 	"github.com/SolarLune/resolv"
 	"github.com/hajimehoshi/ebiten"
-	"github.com/hajimehoshi/ebiten/ebitenutil"
 	"github.com/kvartborg/vector"
 )
 
@@ -17,12 +16,28 @@ type BodyComponent struct {
 	Speed      vector.Vector
 	Object     *resolv.Object
 	OnBump     func(*BodyComponent)
+	Bumped     *BodyComponent // The body OnBump's being called for bumping into, or nil if it bumped into the map
+	Flying     bool           // Flying bodies pass over pits and aren't slowed by water
+	Movement   *Movement      // If set, Speed changes according to it each frame; otherwise it stays as it's set
+	Thrust     vector.Vector  // Which way (and how hard, up to 1) the body's Movement accelerates it
+	Hurtbox    *Rect          // Relative to the body's center; if nil, the body itself is what can be hurt
+	HitsBodies bool           // If the body bumps into other bodies' hurtboxes (except its Owner's), like a bullet
+	Owner      *GameObject
+}
+
+type Rect struct {
+	X, Y, W, H float64
+}
+
+func (r Rect) Overlaps(other Rect) bool {
+	return r.X < other.X+other.W && other.X < r.X+r.W && r.Y < other.Y+other.H && other.Y < r.Y+r.H
 }
 
 func NewBodyComponent(x, y, w, h float64, space *resolv.Space) *BodyComponent {
 
 	return &BodyComponent{
 		Speed:  vector.Vector{0, 0},
+		Thrust: vector.Vector{0, 0},
 		Object: resolv.NewObject(x, y, w, h, space),
 	}
 
@@ -30,13 +45,48 @@ func NewBodyComponent(x, y, w, h float64, space *resolv.Space) *BodyComponent {
 
 func (b *BodyComponent) OnAdd(g *GameObject) {
 	b.GameObject = g
+	center := b.Center()
+	g.Level.Spatial.Update(g, center[0], center[1])
 }
 
-func (b *BodyComponent) OnRemove(g *GameObject) { b.Object.Remove() }
+func (b *BodyComponent) OnRemove(g *GameObject) {
+	b.Object.Remove()
+	g.Level.Spatial.Remove(g)
+}
 
 func (b *BodyComponent) Update(screen *ebiten.Image) {
 
-	if col := b.Object.Check(b.Speed[0], 0, "solid"); col.Valid() {
+	cx, cy := b.Object.Center()
+	surface := b.GameObject.Level.SurfaceAt(cx, cy)
+
+	if b.Movement != nil {
+		friction := 1.0
+		if !b.Flying {
+			friction = surface.Friction
+		}
+		b.Speed = b.Movement.Step(b.Speed, b.Thrust, friction)
+	}
+
+	tags := []string{"solid"}
+	move := b.Speed.Clone()
+
+	if !b.Flying {
+		tags = append(tags, "pit")
+		move.Scale(surface.SpeedModifier)
+	}
+
+	if b.HitsBodies {
+		if hit := b.BodyHit(move[0], move[1]); hit != nil {
+			b.Bumped = hit
+			if b.OnBump != nil {
+				b.OnBump(b)
+			}
+			b.Bumped = nil
+			return
+		}
+	}
+
+	if col := b.Object.Check(move[0], 0, tags...); col.Valid() {
 		if b.OnBump != nil {
 			b.OnBump(b)
 		}
@@ -47,10 +97,10 @@ func (b *BodyComponent) Update(screen *ebiten.Image) {
 			b.Speed[0] = 0
 		}
 	} else {
-		b.Object.X += b.Speed[0]
+		b.Object.X += move[0]
 	}
 
-	if col := b.Object.Check(0, b.Speed[1], "solid"); col.Valid() {
+	if col := b.Object.Check(0, move[1], tags...); col.Valid() {
 		if b.OnBump != nil {
 			b.OnBump(b)
 		}
@@ -61,22 +111,22 @@ func (b *BodyComponent) Update(screen *ebiten.Image) {
 			b.Speed[1] = 0
 		}
 	} else {
-		b.Object.Y += b.Speed[1]
+		b.Object.Y += move[1]
 	}
 
 	b.Object.Update()
 
+	center := b.Center()
+	b.GameObject.Level.Spatial.Update(b.GameObject, center[0], center[1])
+
 	if b.GameObject.Level.Game.DebugMode {
 
-		x, y := b.Object.X-b.GameObject.Level.CameraOffsetX, b.Object.Y-b.GameObject.Level.CameraOffsetY
-		w, h := b.Object.W, b.Object.H
+		b.GameObject.Level.Camera.StrokeRect(screen, b.Object.X, b.Object.Y, b.Object.W, b.Object.H, color.RGBA{255, 0, 0, 192})
 
-		red := color.RGBA{255, 0, 0, 192}
-		ebitenutil.DrawLine(screen, x, y, x+w, y, red)
-		ebitenutil.DrawLine(screen, x+w, y, x+w, y+h, red)
-		ebitenutil.DrawLine(screen, x+w, y+h, x, y+h, red)
-		ebitenutil.DrawLine(screen, x, y+h, x, y, red)
-		// ebitenutil.DrawRect(screen, float64(rect.X)-level.CameraOffsetX, float64(rect.Y)-level.CameraOffsetY, float64(rect.W), float64(rect.H), color.RGBA{uintX, uintY, 0, 128})
+		if b.Hurtbox != nil {
+			hurtbox := b.HurtboxRect()
+			b.GameObject.Level.Camera.StrokeRect(screen, hurtbox.X, hurtbox.Y, hurtbox.W, hurtbox.H, color.RGBA{0, 0, 255, 192})
+		}
 
 	}
 
@@ -84,6 +134,41 @@ func (b *BodyComponent) Update(screen *ebiten.Image) {
 
 func (b *BodyComponent) Type() string { return TypeBodyComponent }
 
+// BodyHit returns the body whose hurtbox this body would run into moving by dx, dy, or nil if there's none. The body's
+// Owner and other bodies that hit bodies (like other bullets) are passed through.
+func (b *BodyComponent) BodyHit(dx, dy float64) *BodyComponent {
+
+	area := Rect{X: b.Object.X + dx, Y: b.Object.Y + dy, W: b.Object.W, H: b.Object.H}
+	center := b.Center()
+
+	for _, g := range b.GameObject.Level.Spatial.QueryRadius(center[0]+dx, center[1]+dy, 32) {
+
+		if g == b.GameObject || g == b.Owner {
+			continue
+		}
+
+		if o := g.GetComponent(TypeBodyComponent); o != nil {
+			other := o.(*BodyComponent)
+			if !other.HitsBodies && area.Overlaps(other.HurtboxRect()) {
+				return other
+			}
+		}
+
+	}
+
+	return nil
+
+}
+
+// HurtboxRect returns the area of the world the body can be hurt in.
+func (b *BodyComponent) HurtboxRect() Rect {
+	if b.Hurtbox != nil {
+		center := b.Center()
+		return Rect{X: center[0] + b.Hurtbox.X, Y: center[1] + b.Hurtbox.Y, W: b.Hurtbox.W, H: b.Hurtbox.H}
+	}
+	return Rect{X: b.Object.X, Y: b.Object.Y, W: b.Object.W, H: b.Object.H}
+}
+
 func (b *BodyComponent) Center() vector.Vector {
 	return vector.Vector{
 		b.Object.X + (b.Object.W / 2),
@@ -4,55 +4,24 @@ import "github.com/hajimehoshi/ebiten"
 
 const TypeCameraFollowComponent = "CameraFollow"
 
+// CameraFollowComponent makes its GameObject one of the targets of the Level's Camera while it's in the Level.
 type CameraFollowComponent struct {
-	GameObject       *GameObject
-	OffsetX, OffsetY float64
-	Softness         float64
+	GameObject *GameObject
 }
 
 func NewCameraFollowComponent() *CameraFollowComponent {
-	return &CameraFollowComponent{Softness: 0.1}
+	return &CameraFollowComponent{}
 }
 
 func (cf *CameraFollowComponent) OnAdd(g *GameObject) {
 	cf.GameObject = g
-	cf.OffsetX = float64(cf.GameObject.Level.Game.Width / 2)
-	cf.OffsetY = float64(cf.GameObject.Level.Game.Height / 2)
-
+	g.Level.Camera.AddTarget(g)
 }
-func (cf *CameraFollowComponent) OnRemove(g *GameObject) {}
-
-func (cf *CameraFollowComponent) Update(screen *ebiten.Image) {
-
-	b := cf.GameObject.GetComponent(TypeBodyComponent)
-
-	if b != nil {
-
-		body := b.(*BodyComponent)
-
-		tx := body.Object.X - cf.OffsetX - cf.GameObject.Level.CameraOffsetX
-		cf.GameObject.Level.CameraOffsetX += tx * cf.Softness
-
-		ty := body.Object.Y - cf.OffsetY - cf.GameObject.Level.CameraOffsetY
-		cf.GameObject.Level.CameraOffsetY += ty * cf.Softness
-
-		if cf.GameObject.Level.CameraOffsetX < 0 {
-			cf.GameObject.Level.CameraOffsetX = 0
-		}
-		if cf.GameObject.Level.CameraOffsetY < 0 {
-			cf.GameObject.Level.CameraOffsetY = 0
-		}
-
-		if cf.GameObject.Level.CameraOffsetX+float64(cf.GameObject.Level.Game.Width) > float64(cf.GameObject.Level.Width()) {
-			cf.GameObject.Level.CameraOffsetX = float64(cf.GameObject.Level.Width() - cf.GameObject.Level.Game.Width)
-		}
-
-		if cf.GameObject.Level.CameraOffsetY+float64(cf.GameObject.Level.Game.Height) > float64(cf.GameObject.Level.Height()) {
-			cf.GameObject.Level.CameraOffsetY = float64(cf.GameObject.Level.Height() - cf.GameObject.Level.Game.Height)
-		}
-
-	}
 
+func (cf *CameraFollowComponent) OnRemove(g *GameObject) {
+	g.Level.Camera.RemoveTarget(g)
 }
 
+func (cf *CameraFollowComponent) Update(screen *ebiten.Image) {}
+
 func (cf *CameraFollowComponent) Type() string { return TypeCameraFollowComponent }
@@ -4,29 +4,71 @@ import "github.com/hajimehoshi/ebiten"
 
 const TypeDepthSortComponent = "DepthSort"
 
+// Render layers, from the bottom up. Sprites are drawn a layer at a time, sorted by depth within each one. Particles
+// are drawn over all of them.
+const (
+	RenderLayerFloor   = iota // Things lying on the ground, like shadows, under anything standing on it
+	RenderLayerObjects        // Characters, bullets and anything else without a DepthSortComponent
+)
+
+// RenderLayerGameObject puts a SpriteLayer on whichever render layer its GameObject's on.
+const RenderLayerGameObject = -1
+
+// RenderKey is where a sprite's drawn in the stack: which layer it's on, and how deep it is within that layer.
+type RenderKey struct {
+	Layer int
+	Depth float64
+}
+
+// Less returns if the key's drawn before (under) the other one.
+func (key RenderKey) Less(other RenderKey) bool {
+	if key.Layer != other.Layer {
+		return key.Layer < other.Layer
+	}
+	return key.Depth < other.Depth
+}
+
+// DepthSortComponent sets where its GameObject's sprites are sorted. It should come after the GameObject's
+// BodyComponent and before its DrawComponent, so the Key's up to date by the time the sprites are drawn.
 type DepthSortComponent struct {
 	GameObject *GameObject
+	Layer      int
 	Depth      float64
 	YSort      bool
+	Key        RenderKey // As of the component's last update
 }
 
 func NewDepthSortComponent(autoYSort bool) *DepthSortComponent {
-	return &DepthSortComponent{YSort: autoYSort}
+	return &DepthSortComponent{
+		Layer: RenderLayerObjects,
+		YSort: autoYSort,
+		Key:   RenderKey{Layer: RenderLayerObjects},
+	}
 }
 
-func (ds *DepthSortComponent) OnAdd(g *GameObject) { ds.GameObject = g }
+func (ds *DepthSortComponent) OnAdd(g *GameObject) {
+	ds.GameObject = g
+	if d := g.GetComponent(TypeDrawComponent); d != nil {
+		d.(*DrawComponent).DepthSort = ds
+	}
+}
 
-func (ds *DepthSortComponent) OnRemove(g *GameObject) {}
+func (ds *DepthSortComponent) OnRemove(g *GameObject) {
+	if d := g.GetComponent(TypeDrawComponent); d != nil && d.(*DrawComponent).DepthSort == ds {
+		d.(*DrawComponent).DepthSort = nil
+	}
+}
 
 func (ds *DepthSortComponent) Update(screen *ebiten.Image) {
 
 	if ds.YSort {
 		if b := ds.GameObject.GetComponent(TypeBodyComponent); b != nil {
-			body := b.(*BodyComponent)
-			ds.Depth = body.Center()[1]
+			ds.Depth = b.(*BodyComponent).Center()[1]
 		}
 	}
 
+	ds.Key = RenderKey{Layer: ds.Layer, Depth: ds.Depth}
+
 }
 
 func (ds *DepthSortComponent) Type() string { return TypeDepthSortComponent }
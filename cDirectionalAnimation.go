@@ -0,0 +1,176 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/kvartborg/vector"
+)
+
+const TypeDirectionalAnimationComponent = "DirectionalAnimation"
+
+// DirectionSet names the animation tags to play for each direction. Left-facing directions play the right-facing
+// tags, flipped.
+type DirectionSet struct {
+	Up, UpRight, Right, DownRight, Down string
+}
+
+// Tag returns the tag for the given direction (each axis being -1, 0 or 1), or a blank string if there's none.
+func (set DirectionSet) Tag(x, y int) string {
+	if y < 0 && x != 0 {
+		return set.UpRight
+	} else if y < 0 {
+		return set.Up
+	} else if y == 0 && x != 0 {
+		return set.Right
+	} else if y > 0 && x != 0 {
+		return set.DownRight
+	} else if y > 0 {
+		return set.Down
+	}
+	return ""
+}
+
+// DirectionalAnimationComponent picks which of its GameObject's animations to play from the way its body's moving
+// (or the way it's attacking), and flips its DrawComponent to face left or right. It loops the Walk set while moving
+// and the Idle set while standing still, and StartAttack plays the Attack set through once; tags that are blank or
+// missing from the Aseprite file fall back to the Walk set. If the Idle tags are the Walk tags, standing still just
+// holds the first frame of the walk. Nothing gets played over a one-shot animation until it's done.
+type DirectionalAnimationComponent struct {
+	GameObject    *GameObject
+	Walk          DirectionSet
+	Idle          DirectionSet
+	Attack        DirectionSet
+	MoveThreshold float64 // How fast the body must move to count as walking
+	Threshold     float64 // How far (from 0 to 1) the movement must lean along an axis to face that way
+	Hysteresis    float64 // How much further past (or back under) Threshold the movement must go to change facing
+	Facing        vector.Vector
+	xDir, yDir    int
+}
+
+func NewDirectionalAnimationComponent() *DirectionalAnimationComponent {
+	walk := DirectionSet{Up: "u", UpRight: "ur", Right: "r", DownRight: "dr", Down: "d"}
+	return &DirectionalAnimationComponent{
+		Walk:          walk,
+		Idle:          walk,
+		MoveThreshold: 0.1,
+		Threshold:     0.38, // About 22.5 degrees
+		Hysteresis:    0.1,
+		Facing:        vector.Vector{0, 1},
+		yDir:          1,
+	}
+}
+
+func (da *DirectionalAnimationComponent) OnAdd(g *GameObject) { da.GameObject = g }
+
+func (da *DirectionalAnimationComponent) OnRemove(g *GameObject) {}
+
+func (da *DirectionalAnimationComponent) Update(screen *ebiten.Image) {
+
+	a := da.GameObject.GetComponent(TypeAnimationComponent)
+	if a == nil {
+		return
+	}
+
+	anim := a.(*AnimationComponent)
+
+	if !anim.Busy() {
+
+		moving := false
+
+		if b := da.GameObject.GetComponent(TypeBodyComponent); b != nil {
+			body := b.(*BodyComponent)
+			if body.Speed.Magnitude() > da.MoveThreshold {
+				moving = true
+				da.Face(body.Speed)
+			}
+		}
+
+		set := da.Walk
+		if !moving {
+			set = da.Idle
+		}
+
+		walkTag := da.Walk.Tag(da.xDir, da.yDir)
+		tag := da.tag(anim, set)
+
+		if tag != "" {
+			anim.Transition(tag)
+		}
+
+		if !moving && tag == walkTag {
+			anim.Ase.PlaySpeed = 0
+			if anim.Ase.CurrentAnimation != nil {
+				anim.Ase.CurrentFrame = anim.Ase.CurrentAnimation.Start
+			}
+		}
+
+	}
+
+	if d := da.GameObject.GetComponent(TypeDrawComponent); d != nil {
+		draw := d.(*DrawComponent)
+		if da.xDir < 0 {
+			draw.FlipHorizontal = true
+		} else if da.xDir > 0 {
+			draw.FlipHorizontal = false
+		}
+	}
+
+}
+
+// axisDirection returns which way (-1, 0 or 1) to face along an axis, given the unit facing's component along it and
+// the way the GameObject faced along it before.
+func (da *DirectionalAnimationComponent) axisDirection(value float64, current int) int {
+
+	threshold := da.Threshold + da.Hysteresis
+	if current != 0 && math.Signbit(value) == (current < 0) {
+		threshold = da.Threshold - da.Hysteresis
+	}
+
+	if math.Abs(value) > threshold {
+		if value < 0 {
+			return -1
+		}
+		return 1
+	}
+
+	return 0
+
+}
+
+// Face turns towards the direction.
+func (da *DirectionalAnimationComponent) Face(direction vector.Vector) {
+	if direction.Magnitude() > 0 {
+		da.Facing = vector.Unit(direction)
+		da.xDir = da.axisDirection(da.Facing[0], da.xDir)
+		da.yDir = da.axisDirection(da.Facing[1], da.yDir)
+	}
+}
+
+// StartAttack faces the given direction and plays the Attack set's animation for it through once, returning the tag
+// played. If the file doesn't have that animation, it plays nothing and returns a blank string.
+func (da *DirectionalAnimationComponent) StartAttack(direction vector.Vector) string {
+
+	da.Face(direction)
+
+	if a := da.GameObject.GetComponent(TypeAnimationComponent); a != nil {
+		anim := a.(*AnimationComponent)
+		if tag := da.Attack.Tag(da.xDir, da.yDir); tag != "" && anim.Ase.HasAnimation(tag) {
+			anim.PlayOnce(tag)
+			return tag
+		}
+	}
+
+	return ""
+
+}
+
+// tag returns the set's tag for the current facing, falling back to the Walk set's if the file doesn't have it.
+func (da *DirectionalAnimationComponent) tag(anim *AnimationComponent, set DirectionSet) string {
+	if tag := set.Tag(da.xDir, da.yDir); tag != "" && anim.Ase.HasAnimation(tag) {
+		return tag
+	}
+	return da.Walk.Tag(da.xDir, da.yDir)
+}
+
+func (da *DirectionalAnimationComponent) Type() string { return TypeDirectionalAnimationComponent }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"image"
+	"image/color"
+	"math"
 
 	"github.com/hajimehoshi/ebiten"
 	"github.com/kvartborg/vector"
@@ -13,23 +15,68 @@ type DrawComponent struct {
 	GameObject     *GameObject
 	FlipHorizontal bool
 	Rotation       float64
+	Scale          vector.Vector
+	Pivot          vector.Vector // The point flipping, scaling and rotation happen around, from 0 to 1 across the frame
 	Offset         vector.Vector
 	Visible        bool
+	Tint           color.Color // Multiplies the sprite's colors; nil leaves them as they are
+	Alpha          float64
+	Additive       bool // Adds the sprite's colors onto what's under it instead of covering it, for glows
+	FlashColor     color.Color
+	FlashFrames    int // How many more frames the sprite shows as a solid FlashColor silhouette
+	Layers         []*SpriteLayer
+	HiddenInFog    bool                // If the sprite's hidden while it's somewhere the player can't see
+	DepthSort      *DepthSortComponent // The GameObject's DepthSortComponent, if it has one
 }
 
 func NewDrawComponent(offsetX, offsetY float64) *DrawComponent {
-	return &DrawComponent{Offset: vector.Vector{offsetX, offsetY}, Visible: true}
+	return &DrawComponent{
+		Offset:     vector.Vector{offsetX, offsetY},
+		Scale:      vector.Vector{1, 1},
+		Pivot:      vector.Vector{0.5, 0.5},
+		Visible:    true,
+		Alpha:      1,
+		FlashColor: color.White,
+	}
 }
 
 func (d *DrawComponent) OnAdd(g *GameObject) {
 	d.GameObject = g
+	if ds := g.GetComponent(TypeDepthSortComponent); ds != nil {
+		d.DepthSort = ds.(*DepthSortComponent)
+	}
 }
 
 func (d *DrawComponent) OnRemove(g *GameObject) {}
 
 func (d *DrawComponent) Update(screen *ebiten.Image) {
 
-	if d.Visible && d.GameObject.GetComponent(TypeAnimationComponent) != nil {
+	if d.FlashFrames > 0 {
+		defer func() { d.FlashFrames-- }()
+	}
+
+	for _, layer := range d.Layers {
+		layer.Animation.Update(screen)
+	}
+
+	if !d.Visible {
+		return
+	}
+
+	if fog := d.GameObject.Level.Fog; d.HiddenInFog && fog != nil {
+		if anchor := d.Anchor(); !fog.VisibleAt(anchor[0], anchor[1]) {
+			return
+		}
+	}
+
+	var body *BodyComponent
+	if b := d.GameObject.GetComponent(TypeBodyComponent); b != nil {
+		body = b.(*BodyComponent)
+	}
+
+	key := d.RenderKey(body)
+
+	if d.GameObject.GetComponent(TypeAnimationComponent) != nil {
 
 		geoM := ebiten.GeoM{}
 		anim := d.GameObject.GetComponent(TypeAnimationComponent).(*AnimationComponent)
@@ -39,38 +86,171 @@ func (d *DrawComponent) Update(screen *ebiten.Image) {
 		img := anim.Image.SubImage(image.Rect(int(x), int(y), int(x+anim.Ase.FrameWidth), int(y+anim.Ase.FrameHeight))).(*ebiten.Image)
 		srcW, srcH := img.Size()
 
-		if d.GameObject.GetComponent(TypeBodyComponent) != nil {
-			body := d.GameObject.GetComponent(TypeBodyComponent).(*BodyComponent)
+		if body != nil {
 			bodyX += body.Object.X - ((float64(srcW) - body.Object.W) / 2)
 			bodyY += body.Object.Y - ((float64(srcH) - body.Object.H) / 2)
 		}
 
-		if d.Rotation != 0 {
+		pivotX := float64(srcW) * d.Pivot[0]
+		pivotY := float64(srcH) * d.Pivot[1]
+
+		// Generous enough to cover the sprite however it's scaled and rotated around its pivot
+		reach := math.Max(math.Abs(d.Scale[0]), math.Abs(d.Scale[1])) * math.Hypot(float64(srcW), float64(srcH))
+		if d.GameObject.Level.InView(bodyX+pivotX-reach, bodyY+pivotY-reach, reach*2, reach*2) {
 
-			geoM.Translate(-float64(anim.Ase.FrameWidth/2), -float64(anim.Ase.FrameHeight/2))
+			scaleX := d.Scale[0]
+			if d.FlipHorizontal {
+				scaleX = -scaleX
+			}
+
+			geoM.Translate(-pivotX, -pivotY)
+			geoM.Scale(scaleX, d.Scale[1])
 			geoM.Rotate(d.Rotation)
-			geoM.Translate(float64(anim.Ase.FrameWidth/2), float64(anim.Ase.FrameHeight/2))
+			geoM.Translate(pivotX, pivotY)
+
+			geoM.Translate(bodyX, bodyY)
+			geoM.Concat(d.GameObject.Level.Camera.GeoM())
+
+			opt := &ebiten.DrawImageOptions{GeoM: geoM, ColorM: d.ColorM()}
+
+			if d.Additive {
+				opt.CompositeMode = ebiten.CompositeModeLighter
+			}
+
+			d.GameObject.Level.QueueSprite(key, img, opt)
 
 		}
 
-		colorM := ebiten.ColorM{}
+	}
 
-		colorM.ChangeHSV(0, 1, 0)
+	for _, layer := range d.Layers {
+		if layer.Visible {
+			d.drawLayer(layer, key)
+		}
+	}
 
-		if d.FlipHorizontal {
+}
+
+// drawLayer queues up the layer's current frame to be drawn on its render layer, at the GameObject's depth plus the
+// layer's DepthBias.
+func (d *DrawComponent) drawLayer(layer *SpriteLayer, key RenderKey) {
 
-			geoM.Translate(-float64(srcW/2), -float64(srcH/2))
-			geoM.Scale(-1, 1)
-			geoM.Translate(float64(srcW/2), float64(srcH/2))
+	anim := layer.Animation
+	x, y := anim.Ase.GetFrameXY()
+	img := anim.Image.SubImage(image.Rect(int(x), int(y), int(x+anim.Ase.FrameWidth), int(y+anim.Ase.FrameHeight))).(*ebiten.Image)
+	srcW, srcH := img.Size()
+	w, h := float64(srcW), float64(srcH)
 
+	anchor := d.Anchor()
+	offsetX := layer.Offset[0]
+	scaleX := 1.0
+
+	if layer.Flip && d.FlipHorizontal {
+		offsetX = -offsetX
+		scaleX = -1
+	}
+
+	posX, posY := anchor[0]+offsetX, anchor[1]+layer.Offset[1]
+
+	if !d.GameObject.Level.InView(posX-w/2, posY-h/2, w, h) {
+		return
+	}
+
+	geoM := ebiten.GeoM{}
+	geoM.Translate(-w/2, -h/2)
+	geoM.Scale(scaleX, 1)
+	geoM.Translate(posX, posY)
+	geoM.Concat(d.GameObject.Level.Camera.GeoM())
+
+	colorM := ebiten.ColorM{}
+	colorM.Scale(1, 1, 1, layer.Alpha*d.Alpha)
+
+	if layer.Layer != RenderLayerGameObject {
+		key.Layer = layer.Layer
+	}
+	key.Depth += layer.DepthBias
+	d.GameObject.Level.QueueSprite(key, img, &ebiten.DrawImageOptions{GeoM: geoM, ColorM: colorM})
+
+}
+
+// AddLayer adds sprite layers to draw along with the GameObject's sprite. A layer's animation fires its events for the
+// DrawComponent's GameObject.
+func (d *DrawComponent) AddLayer(layers ...*SpriteLayer) {
+	for _, layer := range layers {
+		layer.Animation.OnAdd(d.GameObject)
+		d.Layers = append(d.Layers, layer)
+	}
+}
+
+func (d *DrawComponent) RemoveLayer(layer *SpriteLayer) {
+	for i, l := range d.Layers {
+		if l == layer {
+			d.Layers = append(d.Layers[:i], d.Layers[i+1:]...)
+			return
 		}
+	}
+}
 
-		geoM.Translate(bodyX-d.GameObject.Level.CameraOffsetX, bodyY-d.GameObject.Level.CameraOffsetY)
+// Layer returns the sprite layer with the given name, or nil if there isn't one.
+func (d *DrawComponent) Layer(name string) *SpriteLayer {
+	for _, layer := range d.Layers {
+		if layer.Name == name {
+			return layer
+		}
+	}
+	return nil
+}
 
-		screen.DrawImage(img, &ebiten.DrawImageOptions{GeoM: geoM})
+// RenderKey returns where the GameObject's sprite is sorted: by its DepthSortComponent, or without one, on the
+// RenderLayerObjects layer at the center of its body (which can be nil).
+func (d *DrawComponent) RenderKey(body *BodyComponent) RenderKey {
+	if d.DepthSort != nil {
+		return d.DepthSort.Key
+	}
+	if body != nil {
+		return RenderKey{Layer: RenderLayerObjects, Depth: body.Center()[1]}
+	}
+	return RenderKey{Layer: RenderLayerObjects}
+}
+
+// Anchor returns the world position sprite layers are placed from: the center of the GameObject's body, or the
+// DrawComponent's Offset without one.
+func (d *DrawComponent) Anchor() vector.Vector {
+	if b := d.GameObject.GetComponent(TypeBodyComponent); b != nil {
+		return b.(*BodyComponent).Center()
+	}
+	return d.Offset.Clone()
+}
+
+// ColorM returns the color matrix the sprite's drawn with this frame, from its Tint, Alpha and any flash.
+func (d *DrawComponent) ColorM() ebiten.ColorM {
 
+	colorM := ebiten.ColorM{}
+
+	if d.FlashFrames > 0 {
+		r, g, b, _ := colorToScale(d.FlashColor)
+		colorM.Scale(0, 0, 0, 1)
+		colorM.Translate(r, g, b, 0)
+	} else if d.Tint != nil {
+		r, g, b, a := colorToScale(d.Tint)
+		colorM.Scale(r, g, b, a)
 	}
 
+	colorM.Scale(1, 1, 1, d.Alpha)
+
+	return colorM
+
+}
+
+// Flash shows the sprite as a solid FlashColor silhouette for the given number of frames.
+func (d *DrawComponent) Flash(frames int) {
+	d.FlashFrames = frames
+}
+
+// colorToScale returns the color's (non-premultiplied) channels from 0 to 1, for ColorM.
+func colorToScale(clr color.Color) (float64, float64, float64, float64) {
+	c := color.NRGBAModel.Convert(clr).(color.NRGBA)
+	return float64(c.R) / 255, float64(c.G) / 255, float64(c.B) / 255, float64(c.A) / 255
 }
 
 func (d *DrawComponent) Type() string { return TypeDrawComponent }
@@ -0,0 +1,57 @@
+package main
+
+import "github.com/hajimehoshi/ebiten"
+
+const TypeHealthComponent = "Health"
+
+type HealthComponent struct {
+	GameObject *GameObject
+	HP, MaxHP  float64
+	OnDeath    func(*HealthComponent)
+}
+
+func NewHealthComponent(maxHP float64) *HealthComponent {
+	return &HealthComponent{HP: maxHP, MaxHP: maxHP}
+}
+
+func (h *HealthComponent) OnAdd(g *GameObject) { h.GameObject = g }
+
+func (h *HealthComponent) OnRemove(g *GameObject) {}
+
+func (h *HealthComponent) Update(screen *ebiten.Image) {}
+
+func (h *HealthComponent) Damage(amount float64) {
+
+	if h.HP <= 0 {
+		return
+	}
+
+	h.HP -= amount
+
+	if d := h.GameObject.GetComponent(TypeDrawComponent); d != nil {
+		d.(*DrawComponent).Flash(6)
+	}
+
+	if b := h.GameObject.GetComponent(TypeBodyComponent); b != nil {
+		center := b.(*BodyComponent).Center()
+		h.GameObject.Level.Particles.Add(NewBloodEmitter(center[0], center[1]))
+	}
+
+	if h.HP <= 0 {
+		h.HP = 0
+		if h.OnDeath != nil {
+			h.OnDeath(h)
+		}
+	}
+
+}
+
+// Fraction returns how much health is left, from 0 to 1.
+func (h *HealthComponent) Fraction() float64 {
+	if h.MaxHP <= 0 {
+		return 0
+	}
+	return h.HP / h.MaxHP
+}
+
+func (h *HealthComponent) Type() string { return TypeHealthComponent }
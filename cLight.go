@@ -0,0 +1,34 @@
+package main
+
+import "github.com/hajimehoshi/ebiten"
+
+const TypeLightComponent = "Light"
+
+// LightComponent carries a Light around with its GameObject's body.
+type LightComponent struct {
+	GameObject *GameObject
+	Light      *Light
+}
+
+func NewLightComponent(radius float64) *LightComponent {
+	return &LightComponent{Light: NewLight(0, 0, radius)}
+}
+
+func (lc *LightComponent) OnAdd(g *GameObject) {
+	lc.GameObject = g
+	g.Level.Lighting.Add(lc.Light)
+	lc.Update(nil)
+}
+
+func (lc *LightComponent) OnRemove(g *GameObject) {
+	g.Level.Lighting.Remove(lc.Light)
+}
+
+func (lc *LightComponent) Update(screen *ebiten.Image) {
+	if b := lc.GameObject.GetComponent(TypeBodyComponent); b != nil {
+		center := b.(*BodyComponent).Center()
+		lc.Light.X, lc.Light.Y = center[0], center[1]
+	}
+}
+
+func (lc *LightComponent) Type() string { return TypeLightComponent }
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"math"
-
 	"github.com/hajimehoshi/ebiten"
 	"github.com/hajimehoshi/ebiten/inpututil"
 	"github.com/kvartborg/vector"
@@ -33,10 +31,6 @@ func (pc *PlayerControlComponent) Update(screen *ebiten.Image) {
 
 		body := b.(*BodyComponent)
 
-		accel := float64(0.5)
-		friction := float64(0.25)
-		maxSpeed := float64(2)
-
 		moveDir := vector.Vector{0, 0}
 
 		if ebiten.IsKeyPressed(ebiten.KeyRight) {
@@ -61,82 +55,16 @@ func (pc *PlayerControlComponent) Update(screen *ebiten.Image) {
 			}
 		}
 
-		if body.Speed.Magnitude() < friction {
-			body.Speed[0] = 0
-			body.Speed[1] = 0
-		} else {
-			m := body.Speed.Magnitude()
-			body.Speed.Unit().Scale(m - friction)
+		if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+			center := body.Center()
+			level := pc.GameObject.Level
+			level.ToggleDoor(level.CellAt(center[0]+pc.Facing[0]*12, center[1]+pc.Facing[1]*12))
 		}
 
-		if moveDir.Magnitude() != 0 {
-
-			body.Speed.Add(moveDir.Unit().Scale(accel))
-
-			if body.Speed.Magnitude() > maxSpeed {
-				body.Speed.Unit().Scale(maxSpeed)
-			}
+		body.Thrust = moveDir.Unit()
 
+		if moveDir.Magnitude() != 0 && body.Speed.Magnitude() != 0 {
 			pc.Facing = vector.Unit(body.Speed)
-
-		}
-
-		a := pc.GameObject.GetComponent(TypeAnimationComponent)
-
-		if a != nil {
-
-			anim := a.(*AnimationComponent)
-
-			xc := 0
-			yc := 0
-
-			min := 0.3
-
-			if math.Abs(body.Speed[0]) > min {
-				xc = 1
-			}
-
-			if body.Speed[1] < -min {
-				yc = -1
-			} else if body.Speed[1] > min {
-				yc = 1
-			}
-
-			if yc < 0 && xc != 0 {
-				anim.Ase.Play("ur")
-			} else if yc < 0 && xc == 0 {
-				anim.Ase.Play("u")
-			} else if yc == 0 && xc != 0 {
-				anim.Ase.Play("r")
-			} else if yc > 0 && xc != 0 {
-				anim.Ase.Play("dr")
-			} else if yc > 0 && xc == 0 {
-				anim.Ase.Play("d")
-			}
-
-			if body.Speed.Magnitude() == 0 {
-				anim.Ase.PlaySpeed = 0
-				if anim.Ase.CurrentAnimation != nil {
-					anim.Ase.CurrentFrame = anim.Ase.CurrentAnimation.Start
-				}
-			} else {
-				anim.Ase.PlaySpeed = 1
-			}
-
-			d := pc.GameObject.GetComponent(TypeDrawComponent)
-
-			if d != nil {
-
-				DrawComponent := d.(*DrawComponent)
-
-				if pc.Facing[0] < 0 {
-					DrawComponent.FlipHorizontal = true
-				} else if pc.Facing[0] > 0 {
-					DrawComponent.FlipHorizontal = false
-				}
-
-			}
-
 		}
 
 	}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math"
+
 	"github.com/hajimehoshi/ebiten"
 	"github.com/kvartborg/vector"
 )
@@ -10,9 +12,18 @@ const TypeWeaponComponent = "Weapon"
 type WeaponComponent struct {
 	GameObject    *GameObject
 	Cooldown      int
+	FireRate      int // Frames between shots
 	Projectile    string
 	FireDirection vector.Vector
 	SpawnOffset   vector.Vector
+	FireFrame     int32 // With an attack animation to play, the bullet comes out on this frame of it
+	Ammo          int
+	MaxAmmo       int // 0 for a weapon that never runs out
+	ReloadTime    int // Frames it takes to reload once the weapon's empty
+	Reloading     int // Frames left until the weapon's reloaded
+	OnReload      func(*WeaponComponent)
+	windingUp     string
+	hooked        bool
 }
 
 func NewWeaponComponent() *WeaponComponent {
@@ -25,20 +36,119 @@ func (wp *WeaponComponent) OnAdd(g *GameObject) {
 
 func (wp *WeaponComponent) OnRemove(g *GameObject) {}
 
-func (wp *WeaponComponent) Update(screen *ebiten.Image) {}
+func (wp *WeaponComponent) Update(screen *ebiten.Image) {
+
+	if wp.Cooldown > 0 {
+		wp.Cooldown--
+	}
+
+	if wp.Reloading > 0 {
+		wp.Reloading--
+		if wp.Reloading == 0 {
+			wp.Ammo = wp.MaxAmmo
+		}
+	}
+
+	// The attack animation was cut off (or played out) before the bullet came out on its FireFrame, so it comes out now
+	// rather than getting lost.
+	if wp.windingUp != "" {
+		if a := wp.GameObject.GetComponent(TypeAnimationComponent); a != nil {
+			anim := a.(*AnimationComponent)
+			if current := anim.Ase.CurrentAnimation; current == nil || current.Name != wp.windingUp || !anim.Busy() {
+				wp.windingUp = ""
+				wp.Spawn()
+			}
+		}
+	}
+
+}
+
+// Fire shoots a bullet in the FireDirection, returning false if the weapon's still cooling down or reloading. If the
+// GameObject has an attack animation for that direction, it's played, and the bullet comes out on its FireFrame.
+func (wp *WeaponComponent) Fire() bool {
 
-func (wp *WeaponComponent) Fire() {
+	if wp.Cooldown > 0 || wp.Reloading > 0 {
+		return false
+	}
+
+	wp.Cooldown = wp.FireRate
+
+	if wp.MaxAmmo > 0 {
+		wp.Ammo--
+		if wp.Ammo <= 0 {
+			wp.Reload()
+		}
+	}
+
+	if da := wp.GameObject.GetComponent(TypeDirectionalAnimationComponent); da != nil {
+
+		if tag := da.(*DirectionalAnimationComponent).StartAttack(wp.FireDirection); tag != "" {
+
+			if !wp.hooked {
+				anim := wp.GameObject.GetComponent(TypeAnimationComponent).(*AnimationComponent)
+				anim.OnFrame("", wp.FireFrame, func(anim *AnimationComponent) {
+					if wp.windingUp != "" && anim.Ase.CurrentAnimation.Name == wp.windingUp {
+						wp.windingUp = ""
+						wp.Spawn()
+					}
+				})
+				wp.hooked = true
+			}
+
+			wp.windingUp = tag
+			return true
+
+		}
+
+	}
+
+	wp.Spawn()
+
+	return true
+
+}
+
+// Reload empties the weapon and starts reloading it.
+func (wp *WeaponComponent) Reload() {
+
+	if wp.MaxAmmo <= 0 || wp.Reloading > 0 {
+		return
+	}
+
+	wp.Ammo = 0
+	wp.Reloading = wp.ReloadTime
+
+	if wp.Reloading <= 0 {
+		wp.Ammo = wp.MaxAmmo
+	}
+
+	if wp.OnReload != nil {
+		wp.OnReload(wp)
+	}
+
+}
+
+// Spawn creates the bullet, flying in the FireDirection.
+func (wp *WeaponComponent) Spawn() {
 
 	x, y := 0.0, 0.0
 
 	if bp := wp.GameObject.GetComponent(TypeBodyComponent); bp != nil {
-		goBody := bp.(*BodyComponent)
-		x, y = goBody.Object.X, goBody.Object.Y
+		center := bp.(*BodyComponent).Center()
+		x, y = center[0], center[1]
+	}
+
+	if wp.SpawnOffset != nil {
+		x += wp.SpawnOffset[0]
+		y += wp.SpawnOffset[1]
 	}
 
-	bullet := NewBullet(wp.GameObject.Level, x, y, wp.FireDirection)
+	// Bullets are 4x4, so this centers them on the spawn point
+	bullet := NewBullet(wp.GameObject.Level, wp.GameObject, x-2, y-2, wp.FireDirection)
 	wp.GameObject.Level.Add(bullet)
 
+	wp.GameObject.Level.Particles.Add(NewMuzzleFlashEmitter(x, y, math.Atan2(wp.FireDirection[1], wp.FireDirection[0])))
+
 }
 
 func (wp *WeaponComponent) Type() string { return TypeWeaponComponent }
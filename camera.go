@@ -0,0 +1,249 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// Camera decides which part of the Level is on screen. It follows its Targets, only moving once they leave the
+// Deadzone around the middle of the view, and aiming a little ahead of where they're going. With more than one
+// target, it zooms out (down to MinZoom) to keep them all in frame. When the Level's smaller than the view, it's
+// centered instead of clamped to the Level's edges.
+type Camera struct {
+	Level                *Level
+	X, Y                 float64 // The top-left corner of the view in the world, not counting shake
+	Zoom                 float64
+	BaseZoom             float64 // The zoom the Camera settles at with a single target
+	MinZoom              float64
+	Softness             float64 // How much of the way to its goal the Camera moves each frame
+	DeadzoneW, DeadzoneH float64
+	LookAhead            float64 // How many frames of a target's speed to look ahead by
+	FramePadding         float64 // How much room to leave around targets when framing more than one
+	Targets              []*GameObject
+	Trauma               float64 // How shaken the Camera is, from 0 to 1
+	ShakeDecay           float64 // How much Trauma wears off each frame
+	MaxShake             float64 // How far, in pixels, the view moves at full Trauma
+	ShakeX, ShakeY       float64
+}
+
+func NewCamera(level *Level) *Camera {
+	return &Camera{
+		Level:        level,
+		Zoom:         1,
+		BaseZoom:     1,
+		MinZoom:      0.5,
+		Softness:     0.1,
+		DeadzoneW:    32,
+		DeadzoneH:    24,
+		LookAhead:    16,
+		FramePadding: 48,
+		ShakeDecay:   0.03,
+		MaxShake:     6,
+	}
+}
+
+func (cam *Camera) AddTarget(g *GameObject) {
+	cam.Targets = append(cam.Targets, g)
+}
+
+func (cam *Camera) RemoveTarget(g *GameObject) {
+	for i, t := range cam.Targets {
+		if t == g {
+			cam.Targets = append(cam.Targets[:i], cam.Targets[i+1:]...)
+			return
+		}
+	}
+}
+
+// ViewWidth returns how wide the Camera's view is in the world, accounting for zoom.
+func (cam *Camera) ViewWidth() float64 {
+	return float64(cam.Level.Game.Width) / cam.Zoom
+}
+
+// ViewHeight returns how tall the Camera's view is in the world, accounting for zoom.
+func (cam *Camera) ViewHeight() float64 {
+	return float64(cam.Level.Game.Height) / cam.Zoom
+}
+
+// Shake adds to the Camera's Trauma; an amount of 1 shakes it as hard as it goes.
+func (cam *Camera) Shake(amount float64) {
+	cam.Trauma = math.Min(cam.Trauma+amount, 1)
+}
+
+// ShakeAt shakes the Camera for something that happened at the world position, fading out with distance from the
+// middle of the view so off-screen events barely register.
+func (cam *Camera) ShakeAt(x, y, amount float64) {
+	dist := math.Hypot(x-(cam.X+cam.ViewWidth()/2), y-(cam.Y+cam.ViewHeight()/2))
+	cam.Shake(amount * math.Max(0, 1-dist/cam.ViewWidth()))
+}
+
+// Snap moves the Camera straight to its targets, skipping the deadzone and easing.
+func (cam *Camera) Snap() {
+	if minX, minY, maxX, maxY, ok := cam.targetBounds(); ok {
+		cam.X = (minX+maxX)/2 - cam.ViewWidth()/2
+		cam.Y = (minY+maxY)/2 - cam.ViewHeight()/2
+		cam.clamp()
+	}
+}
+
+func (cam *Camera) Update() {
+
+	if minX, minY, maxX, maxY, ok := cam.targetBounds(); ok {
+
+		zoom := cam.BaseZoom
+
+		if len(cam.Targets) > 1 {
+			fitW := float64(cam.Level.Game.Width) / (maxX - minX + cam.FramePadding*2)
+			fitH := float64(cam.Level.Game.Height) / (maxY - minY + cam.FramePadding*2)
+			zoom = math.Max(math.Min(math.Min(fitW, fitH), cam.BaseZoom), cam.MinZoom)
+		}
+
+		// Zoom around the middle of the view
+		centerX, centerY := cam.X+cam.ViewWidth()/2, cam.Y+cam.ViewHeight()/2
+		cam.Zoom += (zoom - cam.Zoom) * cam.Softness
+		if math.Abs(zoom-cam.Zoom) < 0.001 {
+			cam.Zoom = zoom
+		}
+		cam.X, cam.Y = centerX-cam.ViewWidth()/2, centerY-cam.ViewHeight()/2
+
+		focusX, focusY := (minX+maxX)/2, (minY+maxY)/2
+		goalX, goalY := centerX, centerY
+
+		if dx := focusX - centerX; dx > cam.DeadzoneW/2 {
+			goalX = focusX - cam.DeadzoneW/2
+		} else if dx < -cam.DeadzoneW/2 {
+			goalX = focusX + cam.DeadzoneW/2
+		}
+
+		if dy := focusY - centerY; dy > cam.DeadzoneH/2 {
+			goalY = focusY - cam.DeadzoneH/2
+		} else if dy < -cam.DeadzoneH/2 {
+			goalY = focusY + cam.DeadzoneH/2
+		}
+
+		cam.X += (goalX - centerX) * cam.Softness
+		cam.Y += (goalY - centerY) * cam.Softness
+
+	}
+
+	cam.clamp()
+
+	shake := cam.Trauma * cam.Trauma * cam.MaxShake
+	cam.ShakeX = (rand.Float64()*2 - 1) * shake
+	cam.ShakeY = (rand.Float64()*2 - 1) * shake
+	cam.Trauma = math.Max(cam.Trauma-cam.ShakeDecay, 0)
+
+}
+
+// targetBounds returns the box around where the Camera's targets are (and are heading), and false if it has none.
+func (cam *Camera) targetBounds() (float64, float64, float64, float64, bool) {
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	found := false
+
+	for _, t := range cam.Targets {
+
+		b := t.GetComponent(TypeBodyComponent)
+		if b == nil {
+			continue
+		}
+
+		body := b.(*BodyComponent)
+		center := body.Center()
+		x := center[0] + body.Speed[0]*cam.LookAhead
+		y := center[1] + body.Speed[1]*cam.LookAhead
+
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+		found = true
+
+	}
+
+	return minX, minY, maxX, maxY, found
+
+}
+
+// clamp keeps the view inside of the Level, or centers it on an axis where the Level's smaller than the view.
+func (cam *Camera) clamp() {
+
+	levelW, levelH := float64(cam.Level.Width()), float64(cam.Level.Height())
+	viewW, viewH := cam.ViewWidth(), cam.ViewHeight()
+
+	if levelW <= viewW {
+		cam.X = (levelW - viewW) / 2
+	} else {
+		cam.X = math.Max(0, math.Min(cam.X, levelW-viewW))
+	}
+
+	if levelH <= viewH {
+		cam.Y = (levelH - viewH) / 2
+	} else {
+		cam.Y = math.Max(0, math.Min(cam.Y, levelH-viewH))
+	}
+
+}
+
+// Offset returns the top-left corner of the view in the world this frame, shake and all.
+func (cam *Camera) Offset() (float64, float64) {
+	return cam.X + cam.ShakeX, cam.Y + cam.ShakeY
+}
+
+// GeoM returns the transform from world space to screen space. Anything drawn in the world should end with it.
+func (cam *Camera) GeoM() ebiten.GeoM {
+	x, y := cam.Offset()
+	geoM := ebiten.GeoM{}
+	geoM.Translate(-x, -y)
+	geoM.Scale(cam.Zoom, cam.Zoom)
+	return geoM
+}
+
+// WorldToScreen converts a world position to a position on the Game's screen.
+func (cam *Camera) WorldToScreen(x, y float64) (float64, float64) {
+	ox, oy := cam.Offset()
+	return (x - ox) * cam.Zoom, (y - oy) * cam.Zoom
+}
+
+// ScreenToWorld converts a position on the Game's screen to a world position.
+func (cam *Camera) ScreenToWorld(x, y float64) (float64, float64) {
+	ox, oy := cam.Offset()
+	return x/cam.Zoom + ox, y/cam.Zoom + oy
+}
+
+// CursorWorldPosition returns where in the world the mouse cursor is.
+func (cam *Camera) CursorWorldPosition() (float64, float64) {
+	cx, cy := ebiten.CursorPosition()
+	sx, sy := cam.Level.Game.WindowToScreen(float64(cx), float64(cy))
+	return cam.ScreenToWorld(sx, sy)
+}
+
+// InView returns if the world-space rectangle overlaps the Camera's view.
+func (cam *Camera) InView(x, y, w, h float64) bool {
+	ox, oy := cam.Offset()
+	return x+w >= ox && y+h >= oy && x <= ox+cam.ViewWidth() && y <= oy+cam.ViewHeight()
+}
+
+// DrawLine draws a line between two world positions, for debug overlays.
+func (cam *Camera) DrawLine(screen *ebiten.Image, x1, y1, x2, y2 float64, clr color.Color) {
+	sx1, sy1 := cam.WorldToScreen(x1, y1)
+	sx2, sy2 := cam.WorldToScreen(x2, y2)
+	ebitenutil.DrawLine(screen, sx1, sy1, sx2, sy2, clr)
+}
+
+// DrawRect fills a world-space rectangle, for debug overlays.
+func (cam *Camera) DrawRect(screen *ebiten.Image, x, y, w, h float64, clr color.Color) {
+	sx, sy := cam.WorldToScreen(x, y)
+	ebitenutil.DrawRect(screen, sx, sy, w*cam.Zoom, h*cam.Zoom, clr)
+}
+
+// StrokeRect outlines a world-space rectangle, for debug overlays.
+func (cam *Camera) StrokeRect(screen *ebiten.Image, x, y, w, h float64, clr color.Color) {
+	cam.DrawLine(screen, x, y, x+w, y, clr)
+	cam.DrawLine(screen, x+w, y, x+w, y+h, clr)
+	cam.DrawLine(screen, x+w, y+h, x, y+h, clr)
+	cam.DrawLine(screen, x, y+h, x, y, clr)
+}
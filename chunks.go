@@ -0,0 +1,149 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// TileChunkSize is the width and height of a TileChunk, in tiles.
+const TileChunkSize = 16
+
+// TileChunk is a square section of the map's pre-rendered tiles. Chunks are only rendered when they're dirty and
+// on screen, and only chunks that are on screen get drawn.
+type TileChunk struct {
+	X, Y   int // Position of the chunk, in chunks
+	BG, FG *ebiten.Image
+	Dirty  bool
+}
+
+func (level *Level) InitChunks() {
+
+	w := (level.Map.Width + TileChunkSize - 1) / TileChunkSize
+	h := (level.Map.Height + TileChunkSize - 1) / TileChunkSize
+
+	level.TileChunks = make([][]*TileChunk, h)
+
+	for y := range level.TileChunks {
+		level.TileChunks[y] = make([]*TileChunk, w)
+		for x := range level.TileChunks[y] {
+			level.TileChunks[y][x] = &TileChunk{X: x, Y: y, Dirty: true}
+		}
+	}
+
+}
+
+// RenderTiles marks every chunk to be re-rendered the next time it's on screen.
+func (level *Level) RenderTiles() {
+
+	for _, row := range level.TileChunks {
+		for _, chunk := range row {
+			chunk.Dirty = true
+		}
+	}
+
+}
+
+// RenderTilesAround marks the chunks holding the tile at x, y and its neighbors to be re-rendered, as the neighbors'
+// autotiling depends on it.
+func (level *Level) RenderTilesAround(x, y int) {
+
+	for ty := y - 1; ty <= y+1; ty++ {
+		for tx := x - 1; tx <= x+1; tx++ {
+			if chunk := level.ChunkAt(tx, ty); chunk != nil {
+				chunk.Dirty = true
+			}
+		}
+	}
+
+}
+
+// ChunkAt returns the TileChunk containing the tile at x, y, or nil if it's outside of the map.
+func (level *Level) ChunkAt(x, y int) *TileChunk {
+
+	if x < 0 || y < 0 || x >= level.Map.Width || y >= level.Map.Height {
+		return nil
+	}
+
+	return level.TileChunks[y/TileChunkSize][x/TileChunkSize]
+
+}
+
+// DrawTiles draws the given tile layer's chunks that overlap the camera, rendering any that are dirty first.
+func (level *Level) DrawTiles(screen *ebiten.Image, layer string) {
+
+	geoM := level.Camera.GeoM()
+
+	chunkPixels := float64(TileChunkSize * 16)
+
+	for _, row := range level.TileChunks {
+
+		for _, chunk := range row {
+
+			cx := float64(chunk.X) * chunkPixels
+			cy := float64(chunk.Y) * chunkPixels
+
+			// The extra tile of margin covers rotated tiles.
+			if !level.InView(cx-16, cy-16, chunkPixels+32, chunkPixels+32) {
+				continue
+			}
+
+			if chunk.Dirty {
+				level.renderChunk(chunk)
+			}
+
+			img := chunk.BG
+			if layer == TileLayerFG {
+				img = chunk.FG
+			}
+
+			chunkGeoM := ebiten.GeoM{}
+			chunkGeoM.Translate(cx, cy)
+			chunkGeoM.Concat(geoM)
+			screen.DrawImage(img, &ebiten.DrawImageOptions{GeoM: chunkGeoM})
+
+		}
+
+	}
+
+}
+
+func (level *Level) renderChunk(chunk *TileChunk) {
+
+	size := TileChunkSize * 16
+
+	if chunk.BG == nil {
+		chunk.BG, _ = ebiten.NewImage(size, size, ebiten.FilterNearest)
+		chunk.FG, _ = ebiten.NewImage(size, size, ebiten.FilterNearest)
+	} else {
+		chunk.BG.Fill(color.Transparent)
+		chunk.FG.Fill(color.Transparent)
+	}
+
+	for ty := 0; ty < TileChunkSize; ty++ {
+
+		for tx := 0; tx < TileChunkSize; tx++ {
+
+			x := chunk.X*TileChunkSize + tx
+			y := chunk.Y*TileChunkSize + ty
+
+			terrain, tile := level.Tileset.Tile(level.Map, x, y)
+
+			if tile == nil {
+				continue
+			}
+
+			dst := chunk.BG
+			if terrain.Layer == TileLayerFG {
+				dst = chunk.FG
+			}
+
+			level.Tileset.DrawTile(dst, terrain, tile, float64(tx*16), float64(ty*16))
+
+		}
+
+	}
+
+	chunk.Dirty = false
+
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+
+	"github.com/kvartborg/vector"
+)
+
+// FlowField holds, for every cell of the Level's PathfindingGrid, the cost of walking from that cell to the Target's
+// cell. It's worked out once with Dijkstra's algorithm whenever the Target moves into another cell (or the map
+// changes), and then any number of NPCs can sample it for the way to go instead of each finding a path of their own.
+type FlowField struct {
+	Level            *Level
+	Target           *GameObject
+	Distance         [][]float64
+	TargetX, TargetY int
+	Dirty            bool
+}
+
+func NewFlowField(level *Level, target *GameObject) *FlowField {
+	return &FlowField{
+		Level:   level,
+		Target:  target,
+		TargetX: -1,
+		TargetY: -1,
+		Dirty:   true,
+	}
+}
+
+// Update recomputes the FlowField if its Target has moved into a different cell or the field's been marked Dirty.
+func (ff *FlowField) Update() {
+
+	if ff.Target == nil {
+		return
+	}
+
+	b := ff.Target.GetComponent(TypeBodyComponent)
+	if b == nil {
+		return
+	}
+
+	center := b.(*BodyComponent).Center()
+	x, y := ff.Level.CellAt(center[0], center[1])
+
+	if ff.Dirty || x != ff.TargetX || y != ff.TargetY {
+		ff.Compute(x, y)
+	}
+
+}
+
+// Compute fills out the FlowField's Distance towards the cell at x, y.
+func (ff *FlowField) Compute(targetX, targetY int) {
+
+	grid := ff.Level.PathfindingGrid
+	w, h := ff.Level.Map.Width, ff.Level.Map.Height
+
+	if len(ff.Distance) != h || (h > 0 && len(ff.Distance[0]) != w) {
+		ff.Distance = make([][]float64, h)
+		for y := range ff.Distance {
+			ff.Distance[y] = make([]float64, w)
+		}
+	}
+
+	for y := range ff.Distance {
+		for x := range ff.Distance[y] {
+			ff.Distance[y][x] = math.Inf(1)
+		}
+	}
+
+	ff.TargetX, ff.TargetY = targetX, targetY
+	ff.Dirty = false
+
+	if targetX < 0 || targetY < 0 || targetX >= w || targetY >= h {
+		return
+	}
+
+	ff.Distance[targetY][targetX] = 0
+
+	open := &flowQueue{{X: targetX, Y: targetY}}
+
+	for open.Len() > 0 {
+
+		current := heap.Pop(open).(flowNode)
+
+		// A stale entry; the cell was reached more cheaply since this was queued.
+		if current.Distance > ff.Distance[current.Y][current.X] {
+			continue
+		}
+
+		for _, n := range flowNeighbors {
+
+			nx, ny := current.X+n[0], current.Y+n[1]
+
+			if !ff.Level.Walkable(nx, ny) {
+				continue
+			}
+
+			// Don't cut corners past walls, as NPCs would get stuck on them.
+			if n[0] != 0 && n[1] != 0 && (!ff.Level.Walkable(current.X+n[0], current.Y) || !ff.Level.Walkable(current.X, current.Y+n[1])) {
+				continue
+			}
+
+			cost := math.Max(grid.Get(nx, ny).Cost, 1)
+			if n[0] != 0 && n[1] != 0 {
+				cost *= math.Sqrt2
+			}
+
+			if dist := current.Distance + cost; dist < ff.Distance[ny][nx] {
+				ff.Distance[ny][nx] = dist
+				heap.Push(open, flowNode{X: nx, Y: ny, Distance: dist})
+			}
+
+		}
+
+	}
+
+}
+
+// DistanceAt returns the cost of walking from the cell at x, y to the Target, or +Inf if it can't be reached.
+func (ff *FlowField) DistanceAt(x, y int) float64 {
+	if y < 0 || y >= len(ff.Distance) || x < 0 || x >= len(ff.Distance[y]) {
+		return math.Inf(1)
+	}
+	return ff.Distance[y][x]
+}
+
+// Direction returns a unit vector pointing from the world position towards the center of the neighboring cell that
+// leads to the Target the fastest. It returns nil if the Target can't be reached from there, or if the position's
+// already in the Target's cell.
+func (ff *FlowField) Direction(pos vector.Vector) vector.Vector {
+
+	x, y := ff.Level.CellAt(pos[0], pos[1])
+
+	best := ff.DistanceAt(x, y)
+	if math.IsInf(best, 1) {
+		return nil
+	}
+
+	bestX, bestY := x, y
+
+	for _, n := range flowNeighbors {
+
+		nx, ny := x+n[0], y+n[1]
+
+		if n[0] != 0 && n[1] != 0 && (!ff.Level.Walkable(x+n[0], y) || !ff.Level.Walkable(x, y+n[1])) {
+			continue
+		}
+
+		if d := ff.DistanceAt(nx, ny); d < best {
+			best = d
+			bestX, bestY = nx, ny
+		}
+
+	}
+
+	// Already in the Target's cell, so head straight for it.
+	if bestX == x && bestY == y {
+		return nil
+	}
+
+	return vector.Vector{float64(bestX*16+8) - pos[0], float64(bestY*16+8) - pos[1]}.Unit()
+
+}
+
+var flowNeighbors = [][2]int{
+	{1, 0}, {-1, 0}, {0, 1}, {0, -1},
+	{1, 1}, {-1, 1}, {1, -1}, {-1, -1},
+}
+
+type flowNode struct {
+	X, Y     int
+	Distance float64
+}
+
+// flowQueue is a min-heap of flowNodes for container/heap.
+type flowQueue []flowNode
+
+func (q flowQueue) Len() int            { return len(q) }
+func (q flowQueue) Less(i, j int) bool  { return q[i].Distance < q[j].Distance }
+func (q flowQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *flowQueue) Push(x interface{}) { *q = append(*q, x.(flowNode)) }
+func (q *flowQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// FogOfWar hides the parts of the Level its Viewer hasn't seen. Cells the Viewer can see right now are clear, cells
+// it's seen before are dimmed, and the rest are black. What the Viewer can see is worked out with line of sight over
+// the map whenever it moves into another cell (or the map changes), out to Radius cells away.
+type FogOfWar struct {
+	Level            *Level
+	Viewer           *GameObject
+	Radius           int
+	Explored         [][]bool
+	Visible          [][]bool
+	ExploredAlpha    uint8 // How dark explored cells that aren't in sight are, from 0 to 255
+	ViewerX, ViewerY int
+	Dirty            bool
+	image            *ebiten.Image
+	pixels           []byte
+	imageDirty       bool
+}
+
+func NewFogOfWar(level *Level, viewer *GameObject, radius int) *FogOfWar {
+	return &FogOfWar{
+		Level:         level,
+		Viewer:        viewer,
+		Radius:        radius,
+		ExploredAlpha: 160,
+		ViewerX:       -1,
+		ViewerY:       -1,
+		Dirty:         true,
+	}
+}
+
+// Update recomputes what's visible if the Viewer's moved into a different cell or the fog's been marked Dirty.
+func (fog *FogOfWar) Update() {
+
+	if fog.Viewer == nil {
+		return
+	}
+
+	b := fog.Viewer.GetComponent(TypeBodyComponent)
+	if b == nil {
+		return
+	}
+
+	center := b.(*BodyComponent).Center()
+	x, y := fog.Level.CellAt(center[0], center[1])
+
+	if fog.Dirty || x != fog.ViewerX || y != fog.ViewerY {
+		fog.Compute(x, y)
+	}
+
+}
+
+// Compute works out which cells can be seen from the cell at x, y, and marks them explored.
+func (fog *FogOfWar) Compute(viewerX, viewerY int) {
+
+	w, h := fog.Level.Map.Width, fog.Level.Map.Height
+
+	if len(fog.Visible) != h || (h > 0 && len(fog.Visible[0]) != w) {
+		fog.Visible = make([][]bool, h)
+		fog.Explored = make([][]bool, h)
+		for y := range fog.Visible {
+			fog.Visible[y] = make([]bool, w)
+			fog.Explored[y] = make([]bool, w)
+		}
+	}
+
+	for y := range fog.Visible {
+		for x := range fog.Visible[y] {
+			fog.Visible[y][x] = false
+		}
+	}
+
+	fog.ViewerX, fog.ViewerY = viewerX, viewerY
+	fog.Dirty = false
+	fog.imageDirty = true
+
+	for y := viewerY - fog.Radius; y <= viewerY+fog.Radius; y++ {
+
+		for x := viewerX - fog.Radius; x <= viewerX+fog.Radius; x++ {
+
+			if x < 0 || y < 0 || x >= w || y >= h {
+				continue
+			}
+
+			if math.Hypot(float64(x-viewerX), float64(y-viewerY)) > float64(fog.Radius)+0.5 {
+				continue
+			}
+
+			// The cells at either end don't count, so walls can be seen
+			endX, endY := x, y
+			blocked := func(cx, cy int) bool {
+				return (cx != viewerX || cy != viewerY) && (cx != endX || cy != endY) && fog.Level.Opaque(cx, cy)
+			}
+
+			if fog.Level.ClearLine(viewerX, viewerY, x, y, blocked) {
+				fog.Visible[y][x] = true
+				fog.Explored[y][x] = true
+			}
+
+		}
+
+	}
+
+}
+
+// VisibleAt returns if the cell at the world position can be seen right now.
+func (fog *FogOfWar) VisibleAt(x, y float64) bool {
+	cx, cy := fog.Level.CellAt(x, y)
+	if cy < 0 || cy >= len(fog.Visible) || cx < 0 || cx >= len(fog.Visible[cy]) {
+		return false
+	}
+	return fog.Visible[cy][cx]
+}
+
+// Draw covers the screen in fog. The fog image has a pixel for each cell, and is stretched over the map with linear
+// filtering so its edges are soft.
+func (fog *FogOfWar) Draw(screen *ebiten.Image) {
+
+	if len(fog.Visible) == 0 {
+		return
+	}
+
+	w, h := len(fog.Visible[0]), len(fog.Visible)
+
+	if fog.image == nil {
+		fog.image, _ = ebiten.NewImage(w, h, ebiten.FilterLinear)
+		fog.pixels = make([]byte, w*h*4)
+	}
+
+	if fog.imageDirty {
+
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				alpha := uint8(255)
+				if fog.Visible[y][x] {
+					alpha = 0
+				} else if fog.Explored[y][x] {
+					alpha = fog.ExploredAlpha
+				}
+				// Black, so premultiplying the alpha changes nothing
+				fog.pixels[(y*w+x)*4+3] = alpha
+			}
+		}
+
+		fog.image.ReplacePixels(fog.pixels)
+		fog.imageDirty = false
+
+	}
+
+	geoM := ebiten.GeoM{}
+	geoM.Scale(16, 16)
+	geoM.Concat(fog.Level.Camera.GeoM())
+
+	screen.DrawImage(fog.image, &ebiten.DrawImageOptions{GeoM: geoM})
+
+}
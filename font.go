@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// BitmapFont draws text from a sheet of fixed-size glyphs, laid out in rune order from FirstRune, left to right and
+// top to bottom. Top is how many empty rows of pixels each glyph starts with, so text can be placed by the top of its
+// letters.
+type BitmapFont struct {
+	Image                   *ebiten.Image
+	GlyphWidth, GlyphHeight int
+	FirstRune               rune
+	LineHeight              int
+	Top                     int
+	glyphs                  map[rune]*ebiten.Image
+}
+
+// LoadBitmapFont reads a font's description from a JSON file, which names the glyph sheet next to it.
+func LoadBitmapFont(jsonPath string) (*BitmapFont, error) {
+
+	data, err := ioutil.ReadFile(getPath(jsonPath))
+	if err != nil {
+		return nil, err
+	}
+
+	desc := struct {
+		Image                   string
+		GlyphWidth, GlyphHeight int
+		FirstRune               rune
+		LineHeight              int
+		Top                     int
+	}{}
+
+	if err := json.Unmarshal(data, &desc); err != nil {
+		return nil, err
+	}
+
+	if desc.LineHeight == 0 {
+		desc.LineHeight = desc.GlyphHeight
+	}
+
+	return &BitmapFont{
+		Image:       GetImage(filepath.Join(filepath.Dir(jsonPath), desc.Image)),
+		GlyphWidth:  desc.GlyphWidth,
+		GlyphHeight: desc.GlyphHeight,
+		FirstRune:   desc.FirstRune,
+		LineHeight:  desc.LineHeight,
+		Top:         desc.Top,
+		glyphs:      map[rune]*ebiten.Image{},
+	}, nil
+
+}
+
+// glyph returns the rune's part of the sheet, or nil if the sheet doesn't have it.
+func (font *BitmapFont) glyph(r rune) *ebiten.Image {
+
+	if g, exists := font.glyphs[r]; exists {
+		return g
+	}
+
+	w, h := font.Image.Size()
+	columns := w / font.GlyphWidth
+	index := int(r - font.FirstRune)
+
+	var g *ebiten.Image
+
+	if index >= 0 && index < columns*(h/font.GlyphHeight) {
+		x, y := (index%columns)*font.GlyphWidth, (index/columns)*font.GlyphHeight
+		g = font.Image.SubImage(image.Rect(x, y, x+font.GlyphWidth, y+font.GlyphHeight)).(*ebiten.Image)
+	}
+
+	font.glyphs[r] = g
+	return g
+
+}
+
+// Measure returns how wide and tall the text is.
+func (font *BitmapFont) Measure(text string) (int, int) {
+
+	lines := strings.Split(text, "\n")
+	w := 0
+
+	for _, line := range lines {
+		if lw := len([]rune(line)) * font.GlyphWidth; lw > w {
+			w = lw
+		}
+	}
+
+	return w, len(lines) * font.LineHeight
+
+}
+
+// Draw draws the text in the color with its top-left corner at x, y on the screen.
+func (font *BitmapFont) Draw(screen *ebiten.Image, text string, x, y float64, clr color.Color) {
+
+	opt := &ebiten.DrawImageOptions{}
+	r, g, b, a := colorToScale(clr)
+	opt.ColorM.Scale(r, g, b, a)
+
+	cx, cy := x, y-float64(font.Top)
+
+	for _, c := range text {
+
+		if c == '\n' {
+			cx = x
+			cy += float64(font.LineHeight)
+			continue
+		}
+
+		if glyph := font.glyph(c); glyph != nil {
+			opt.GeoM.Reset()
+			opt.GeoM.Translate(cx, cy)
+			screen.DrawImage(glyph, opt)
+		}
+
+		cx += float64(font.GlyphWidth)
+
+	}
+
+}
+
+// DrawShadowed draws the text with a dark drop shadow under it, so it stands out over the game.
+func (font *BitmapFont) DrawShadowed(screen *ebiten.Image, text string, x, y float64, clr color.Color) {
+	font.Draw(screen, text, x+1, y+1, color.RGBA{0, 0, 0, 160})
+	font.Draw(screen, text, x, y, clr)
+}
@@ -15,6 +15,8 @@ type GameObject struct {
 	Level      *Level
 	Components []Component
 	ToRemove   []Component
+	// If above 0, the GameObject stops updating while its body is further than this from the camera's view.
+	SleepDistance float64
 }
 
 func NewGameObject(level *Level) *GameObject {
@@ -48,6 +50,22 @@ func (g *GameObject) Update(screen *ebiten.Image) {
 
 }
 
+// Asleep returns if the GameObject is too far from the camera to update; see SleepDistance.
+func (g *GameObject) Asleep() bool {
+
+	if g.SleepDistance <= 0 {
+		return false
+	}
+
+	if b := g.GetComponent(TypeBodyComponent); b != nil {
+		center := b.(*BodyComponent).Center()
+		return g.Level.DistanceFromView(center[0], center[1]) > g.SleepDistance
+	}
+
+	return false
+
+}
+
 func (g *GameObject) AddComponent(components ...Component) {
 
 	for _, component := range components {
@@ -1,34 +1,48 @@
 package main
 
 import (
-	"image"
+	"fmt"
 	"image/color"
+	"log"
 	"math"
-	"math/rand"
 	"sort"
 
-	"github.com/hajimehoshi/ebiten/ebitenutil"
-
 	"github.com/SolarLune/dngn"
 	"github.com/SolarLune/paths"
 	"github.com/SolarLune/resolv"
 	"github.com/hajimehoshi/ebiten"
+	"github.com/kvartborg/vector"
 )
 
 // MAP CELL TYPES
 const FLOOR = ' '
 const WALL = 'x'
+const CRACKED_WALL = '%'
+const DOOR_CLOSED = '+'
+const DOOR_OPEN = '/'
+const WATER = '~'
+const PIT = 'o'
 
 type Level struct {
-	Game                         *Game
-	Map                          *dngn.Room
-	PathfindingGrid              *paths.Grid
-	GameObjects                  []*GameObject
-	ToRemove                     []*GameObject
-	MapImageBG                   *ebiten.Image
-	MapImageFG                   *ebiten.Image
-	Space                        *resolv.Space
-	CameraOffsetX, CameraOffsetY float64
+	Game            *Game
+	Floor           int
+	Player          *GameObject
+	Map             *dngn.Room
+	PathfindingGrid *paths.Grid
+	GameObjects     []*GameObject
+	ToRemove        []*GameObject
+	TileChunks      [][]*TileChunk
+	Tileset         *Tileset
+	Space           *resolv.Space
+	TileObjects     [][]*resolv.Object
+	Spatial         *SpatialHash
+	PathScheduler   *PathScheduler
+	FlowField       *FlowField // Leads to the player
+	Camera          *Camera
+	Particles       *ParticleSystem
+	Lighting        *Lighting
+	Fog             *FogOfWar
+	spriteQueue     spriteQueue
 }
 
 func NewLevel(game *Game) *Level {
@@ -36,15 +50,29 @@ func NewLevel(game *Game) *Level {
 	cellW := 16
 	cellH := 16
 
+	mapW := 60
+	mapH := 60
+
 	level := &Level{
-		Game:        game,
-		Map:         dngn.NewRoom(60, 60),
-		GameObjects: []*GameObject{},
-		Space:       resolv.NewSpace(60, 60, cellW, cellH),
+		Game:          game,
+		Floor:         game.Floor,
+		Map:           dngn.NewRoom(mapW, mapH),
+		GameObjects:   []*GameObject{},
+		Space:         resolv.NewSpace(mapW, mapH, cellW, cellH),
+		Spatial:       NewSpatialHash(64),
+		PathScheduler: NewPathScheduler(4),
 	}
 
-	level.MapImageBG, _ = ebiten.NewImage(level.Map.Width*16, level.Map.Height*16, ebiten.FilterNearest)
-	level.MapImageFG, _ = ebiten.NewImage(level.Map.Width*16, level.Map.Height*16, ebiten.FilterNearest)
+	level.Camera = NewCamera(level)
+	level.Particles = NewParticleSystem(level)
+	level.Lighting = NewLighting(level)
+
+	tileset, err := LoadTileset("assets/tileset_rules.json")
+	if err != nil {
+		log.Println(err)
+		tileset = NewFallbackTileset("assets/tileset.png")
+	}
+	level.Tileset = tileset
 
 	level.Init()
 
@@ -62,12 +90,31 @@ func (level *Level) Init() {
 	// Border
 	level.Map.Select().Shrink(true).Invert().Fill(WALL)
 
+	interior := level.Map.Select().Shrink(true)
+
+	// Water pools and pits only go in open areas, so they can't cut off a corridor
+	interior.ByRune(FLOOR).ByNeighbor(FLOOR, 8, true).ByPercentage(0.02).Expand(1, false).ByRune(FLOOR).Fill(WATER)
+	interior.ByRune(FLOOR).ByNeighbor(FLOOR, 8, true).ByPercentage(0.01).Fill(PIT)
+
+	// Doors go in 1-wide corridors
+	interior.ByRune(FLOOR).By(func(x, y int) bool {
+		m := level.Map
+		horizontal := m.Get(x-1, y) == FLOOR && m.Get(x+1, y) == FLOOR && m.Get(x, y-1) == WALL && m.Get(x, y+1) == WALL
+		vertical := m.Get(x, y-1) == FLOOR && m.Get(x, y+1) == FLOOR && m.Get(x-1, y) == WALL && m.Get(x+1, y) == WALL
+		return horizontal || vertical
+	}).ByPercentage(0.05).Fill(DOOR_CLOSED)
+
+	interior.ByRune(WALL).ByNeighbor(FLOOR, 1, false).ByPercentage(0.05).Fill(CRACKED_WALL)
+
 	// Spawn objects
 
 	player := NewPlayer(level)
 	level.Add(player)
+	level.Player = player
+	level.FlowField = NewFlowField(level, player)
+	level.Fog = NewFogOfWar(level, player, 10)
 
-	npc := NewNPC(level)
+	npc := NewChaser(level)
 	level.Add(npc)
 
 	pb := player.GetComponent(TypeBodyComponent).(*BodyComponent)
@@ -75,60 +122,83 @@ func (level *Level) Init() {
 	npcBody.Object.X = pb.Object.X
 	npcBody.Object.Y = pb.Object.Y
 
-	for _, ci := range level.Map.Select().ByRune(WALL).Cells {
-		cx := float64(ci[0] * 16)
-		cy := float64(ci[1] * 16)
-		obj := resolv.NewObject(cx, cy, 16, 16, level.Space)
-		obj.AddTag("solid")
+	for i, spawnPoint := range level.Map.Select().ByRune(FLOOR).ByPercentage(0.004).Cells {
+
+		var enemy *GameObject
+
+		switch i % 3 {
+		case 0:
+			enemy = NewGuard(level)
+		case 1:
+			enemy = NewTreeNPC(level, "assets/hunter_tree.json")
+		case 2:
+			enemy = NewSkittish(level)
+		}
+
+		enemyBody := enemy.GetComponent(TypeBodyComponent).(*BodyComponent)
+		enemyBody.Object.X = float64(spawnPoint[0] * 16)
+		enemyBody.Object.Y = float64(spawnPoint[1] * 16)
+		level.Add(enemy)
+
 	}
 
-	level.RenderTiles()
+	level.InitCells()
+
+	level.InitChunks()
 
-	level.PathfindingGrid = paths.NewGridFromRuneArrays(level.Map.Data, 16, 16)
-	level.PathfindingGrid.SetWalkable(WALL, false)
+	level.Camera.Snap()
+
+	level.Game.UI.Message(fmt.Sprintf("Floor %d", level.Floor))
 
 }
 
 func (level *Level) Update(screen *ebiten.Image) {
 
-	screen.Fill(color.RGBA{20, 18, 29, 255})
-
-	geoM := ebiten.GeoM{}
-	geoM.Translate(-level.CameraOffsetX, -level.CameraOffsetY-8)
-	screen.DrawImage(level.MapImageBG, &ebiten.DrawImageOptions{GeoM: geoM})
+	if level.FlowField != nil {
+		level.FlowField.Update()
+	}
 
-	// Sort game objects by depth if they've got the component
-	sort.Slice(level.GameObjects, func(i, j int) bool {
+	if level.Fog != nil {
+		level.Fog.Update()
+	}
 
-		if da := level.GameObjects[i].GetComponent(TypeDepthSortComponent); da != nil {
+	level.PathScheduler.Update()
 
-			if db := level.GameObjects[j].GetComponent(TypeDepthSortComponent); db != nil {
+	level.Camera.Update()
 
-				depthA := da.(*DepthSortComponent).Depth
-				depthB := db.(*DepthSortComponent).Depth
-				return depthA < depthB
+	screen.Fill(color.RGBA{20, 18, 29, 255})
 
-			}
+	level.DrawTiles(screen, TileLayerBG)
 
+	// GameObjects update in the order they were added; their sprites are queued up and sorted for drawing afterwards.
+	for _, g := range level.GameObjects {
+		if !g.Asleep() {
+			g.Update(screen)
 		}
+	}
 
-		return false
-	})
+	level.drawSprites(screen)
 
-	for _, g := range level.GameObjects {
-		g.Update(screen)
-	}
+	level.Particles.Update()
+	level.Particles.Draw(screen)
+
+	level.DrawTiles(screen, TileLayerFG)
 
-	screen.DrawImage(level.MapImageFG, &ebiten.DrawImageOptions{GeoM: geoM})
+	level.Lighting.Update()
+	level.Lighting.Draw(screen)
+
+	if level.Fog != nil {
+		level.Fog.Draw(screen)
+	}
 
 	for _, gameObject := range level.ToRemove {
 
 		for i, g := range level.GameObjects {
 
-			g.OnRemove()
-
 			if g == gameObject {
+				g.OnRemove()
 				level.GameObjects = append(level.GameObjects[:i], level.GameObjects[i+1:]...)
+				break
 			}
 
 		}
@@ -139,12 +209,21 @@ func (level *Level) Update(screen *ebiten.Image) {
 
 	if level.Game.DebugMode {
 
-		for y := 0; y < level.Space.Height(); y++ {
+		// Only the cells in view
+		camX, camY := level.Camera.Offset()
+		sx, sy := level.CellAt(camX, camY)
+		ex, ey := level.CellAt(camX+level.Camera.ViewWidth(), camY+level.Camera.ViewHeight())
+
+		for y := sy; y <= ey; y++ {
 
-			for x := 0; x < level.Space.Width(); x++ {
+			for x := sx; x <= ex; x++ {
 
 				cell := level.Space.Cell(x, y)
 
+				if cell == nil {
+					continue
+				}
+
 				cw := float64(level.Space.CellWidth)
 				ch := float64(level.Space.CellHeight)
 				cx := float64(cell.X) * cw
@@ -156,16 +235,7 @@ func (level *Level) Update(screen *ebiten.Image) {
 					drawColor = color.RGBA{255, 255, 0, 255}
 				}
 
-				camX := level.CameraOffsetX
-				camY := level.CameraOffsetY
-
-				ebitenutil.DrawLine(screen, cx-camX, cy-camY, cx-camX+cw, cy-camY, drawColor)
-
-				ebitenutil.DrawLine(screen, cx+cw-camX, cy-camY, cx-camX+cw, cy+ch-camY, drawColor)
-
-				ebitenutil.DrawLine(screen, cx+cw-camX, cy+ch-camY, cx-camX, cy+ch-camY, drawColor)
-
-				ebitenutil.DrawLine(screen, cx-camX, cy+ch-camY, cx-camX, cy-camY, drawColor)
+				level.Camera.StrokeRect(screen, cx, cy, cw, ch, drawColor)
 			}
 
 		}
@@ -174,171 +244,107 @@ func (level *Level) Update(screen *ebiten.Image) {
 
 }
 
-func (level *Level) RenderTiles() {
+// QueueSprite queues up an image to be drawn once all of the GameObjects have updated, in order of render layer and
+// depth. Images with the same key are drawn in the order they were queued.
+func (level *Level) QueueSprite(key RenderKey, img *ebiten.Image, options *ebiten.DrawImageOptions) {
+	level.spriteQueue = append(level.spriteQueue, queuedSprite{Key: key, Image: img, Options: options})
+}
+
+func (level *Level) drawSprites(screen *ebiten.Image) {
 
-	level.MapImageBG.Fill(color.Transparent)
+	sort.Stable(level.spriteQueue)
 
-	tileset := GetImage("assets/tileset.png")
+	for _, sprite := range level.spriteQueue {
+		screen.DrawImage(sprite.Image, sprite.Options)
+	}
 
-	for y := 0; y < level.Map.Height; y++ {
+	level.spriteQueue = level.spriteQueue[:0]
 
-		for x := 0; x < level.Map.Width; x++ {
+}
 
-			srcX := 0
-			srcY := 0
-			rotation := float64(0)
+func (level *Level) Add(g *GameObject) {
+	level.GameObjects = append(level.GameObjects, g)
+}
 
-			value := level.Map.Get(x, y)
+func (level *Level) Remove(g *GameObject) {
+	level.ToRemove = append(level.ToRemove, g)
+}
 
-			switch value {
-			case FLOOR:
-				srcX = 0
-				srcY = 16
-				if rand.Float32() < 0.1 {
-					srcY = 32
-				}
-				if level.Map.Get(x, y-1) == WALL {
-					srcY = 0
-				}
-			case WALL:
+func (level *Level) GetGameObjectByComponent(componentTypeConstant string) []*GameObject {
 
-				left := level.Map.Get(x-1, y) == value
-				right := level.Map.Get(x+1, y) == value
-				up := level.Map.Get(x, y-1) == value
-				down := level.Map.Get(x, y+1) == value
+	goList := []*GameObject{}
 
-				if x == 0 {
-					left = true
-				} else if x == level.Map.Width-1 {
-					right = true
-				}
+	for _, g := range level.GameObjects {
+		if g.GetComponent(componentTypeConstant) != nil {
+			goList = append(goList, g)
+		}
+	}
 
-				if y == 0 {
-					up = true
-				} else if y == level.Map.Height-1 {
-					down = true
-				}
+	return goList
 
-				num := 0
-				if left {
-					num++
-				}
-				if right {
-					num++
-				}
-				if up {
-					num++
-				}
-				if down {
-					num++
-				}
+}
 
-				if num == 4 {
-					srcX = 32
-					srcY = 16
-
-				} else if num == 3 {
-					srcX = 32
-					srcY = 0
-
-					if !right {
-						rotation = math.Pi / 2
-					} else if !down {
-						rotation = math.Pi
-					} else if !left {
-						rotation = -math.Pi / 2
-					}
-
-				} else if num == 2 {
-
-					if right && left {
-						srcX = 48
-						srcY = 16
-						rotation = math.Pi / 2
-					} else if up && down {
-						srcX = 48
-						srcY = 16
-					} else if down && left {
-						// Corners
-						srcX = 48
-						srcY = 0
-					} else if up && left {
-						// Corners
-						srcX = 48
-						srcY = 0
-						rotation = math.Pi / 2
-					} else if up && right {
-						// Corners
-						srcX = 48
-						srcY = 0
-						rotation = math.Pi
-					} else if right && down {
-						// Corners
-						srcX = 48
-						srcY = 0
-						rotation = -math.Pi / 2
-					}
-
-				} else if num == 1 {
-
-					srcX = 16
-					srcY = 0
-
-					if down {
-						rotation = math.Pi / 2
-					} else if left {
-						rotation = math.Pi
-					} else if up {
-						rotation = -math.Pi / 2
-					}
-
-				} else {
-					srcX = 16
-					srcY = 16
-				}
-			}
+// ClearLine returns if none of the map cells on the line between the two cells (both of them included) are blocked,
+// going by the blocked function (like Level.Opaque).
+func (level *Level) ClearLine(startX, startY, endX, endY int, blocked func(x, y int) bool) bool {
 
-			sub := tileset.SubImage(image.Rect(srcX, srcY, srcX+16, srcY+16)).(*ebiten.Image)
+	dx, dy := endX-startX, endY-startY
+	stepX, stepY := 1, 1
+	if dx < 0 {
+		dx, stepX = -dx, -1
+	}
+	if dy < 0 {
+		dy, stepY = -dy, -1
+	}
+	dy = -dy
 
-			geoM := ebiten.GeoM{}
+	x, y := startX, startY
+	e := dx + dy
 
-			geoM.Translate(-8, -8)
-			geoM.Rotate(rotation)
-			geoM.Translate(8, 8)
+	for {
 
-			geoM.Translate(float64(x*16), float64(y*16))
+		if blocked(x, y) {
+			return false
+		}
 
-			if value == FLOOR {
-				level.MapImageBG.DrawImage(sub, &ebiten.DrawImageOptions{GeoM: geoM})
-			} else {
-				level.MapImageFG.DrawImage(sub, &ebiten.DrawImageOptions{GeoM: geoM})
-			}
+		if x == endX && y == endY {
+			return true
+		}
 
+		e2 := e * 2
+		if e2 >= dy {
+			e += dy
+			x += stepX
+		}
+		if e2 <= dx {
+			e += dx
+			y += stepY
 		}
 
 	}
 
 }
 
-func (level *Level) Add(g *GameObject) {
-	level.GameObjects = append(level.GameObjects, g)
+// LineOfSight returns if there are no opaque cells between the two world positions.
+func (level *Level) LineOfSight(from, to vector.Vector) bool {
+	sx, sy := level.CellAt(from[0], from[1])
+	ex, ey := level.CellAt(to[0], to[1])
+	return level.ClearLine(sx, sy, ex, ey, level.Opaque)
 }
 
-func (level *Level) Remove(g *GameObject) {
-	level.ToRemove = append(level.ToRemove, g)
+// InView returns if the world-space rectangle overlaps the camera's view.
+func (level *Level) InView(x, y, w, h float64) bool {
+	return level.Camera.InView(x, y, w, h)
 }
 
-func (level *Level) GetGameObjectByComponent(componentTypeConstant string) []*GameObject {
+// DistanceFromView returns how far the world position is from the edge of the camera's view, or 0 if it's in view.
+func (level *Level) DistanceFromView(x, y float64) float64 {
 
-	goList := []*GameObject{}
-
-	for _, g := range level.GameObjects {
-		if g.GetComponent(componentTypeConstant) != nil {
-			goList = append(goList, g)
-		}
-	}
+	camX, camY := level.Camera.Offset()
+	dx := math.Max(camX-x, x-(camX+level.Camera.ViewWidth()))
+	dy := math.Max(camY-y, y-(camY+level.Camera.ViewHeight()))
 
-	return goList
+	return math.Hypot(math.Max(dx, 0), math.Max(dy, 0))
 
 }
 
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"image/color"
+	"log"
 	"math"
 
 	"github.com/kvartborg/vector"
@@ -12,86 +14,323 @@ func NewPlayer(level *Level) *GameObject {
 
 	spawnPoint := level.Map.Select().ByRune(FLOOR).ByPercentage(0.1).Cells[0]
 
+	body := NewBodyComponent(float64(spawnPoint[0]*16), float64(spawnPoint[1]*16), 8, 8, level.Space)
+	body.Movement = NewWalkingMovement()
+
+	draw := NewDrawComponent(0, 0)
+
+	weapon := NewWeaponComponent()
+	weapon.MaxAmmo, weapon.Ammo = 12, 12
+	weapon.ReloadTime = 60
+	weapon.OnReload = func(w *WeaponComponent) { level.Game.UI.Message("Reloading...") }
+
+	health := NewHealthComponent(10)
+	health.OnDeath = func(h *HealthComponent) { level.Game.UI.Message("You died. Press R to try again.") }
+
+	torch := NewLightComponent(96)
+	torch.Light.Color = color.RGBA{255, 180, 100, 255}
+	torch.Light.Flicker = 0.03
+
 	player.AddComponent(
-		NewBodyComponent(float64(spawnPoint[0]*16), float64(spawnPoint[1]*16), 8, 8, level.Space),
-		NewDrawComponent(0, 0),
+		body,
 		NewDepthSortComponent(true),
+		draw,
 		NewAnimationComponent("assets/npc.json"),
 		NewPlayerControlComponent(),
+		NewDirectionalAnimationComponent(),
 		NewCameraFollowComponent(),
-		NewWeaponComponent(),
+		weapon,
+		health,
+		torch,
 	)
 
+	FitToSlices(player)
+
+	draw.AddLayer(NewShadowLayer(body.Object.H))
+	KickUpDust(player)
+
 	return player
 
 }
 
-func NewNPC(level *Level) *GameObject {
+func NewNPC(level *Level, ai *AIControlComponent) *GameObject {
 
 	npc := NewGameObject(level)
+	npc.SleepDistance = 320
+
+	body := NewBodyComponent(0, 0, 8, 8, level.Space)
+	body.Movement = NewWalkingMovement()
+	body.OnBump = func(b *BodyComponent) {
+		b.GameObject.Level.OpenDoorsInRect(b.Object.X+b.Speed[0], b.Object.Y+b.Speed[1], b.Object.W, b.Object.H)
+	}
+
+	draw := NewDrawComponent(0, 0)
+	draw.HiddenInFog = true
+
+	health := NewHealthComponent(3)
+	health.OnDeath = func(h *HealthComponent) {
+		center := body.Center()
+		level.Remove(npc)
+		Explode(level, center[0], center[1], nil)
+	}
 
 	npc.AddComponent(
-		NewBodyComponent(0, 0, 8, 8, level.Space),
-		NewDrawComponent(0, 0),
+		body,
 		NewDepthSortComponent(true),
+		draw,
 		NewAnimationComponent("assets/npc.json"),
-		NewAIControlComponent(),
+		ai,
+		NewDirectionalAnimationComponent(),
+		health,
 	)
 
+	FitToSlices(npc)
+
+	draw.AddLayer(NewShadowLayer(body.Object.H))
+	KickUpDust(npc)
+
 	return npc
 
 }
 
-func NewBullet(level *Level, x, y float64, movementDirection vector.Vector) *GameObject {
+// KickUpDust puffs dust up from the GameObject's feet each time its walk cycle comes back around.
+func KickUpDust(g *GameObject) {
+
+	anim := g.GetComponent(TypeAnimationComponent).(*AnimationComponent)
+	body := g.GetComponent(TypeBodyComponent).(*BodyComponent)
+
+	anim.OnTagStart("", func(anim *AnimationComponent) {
+		if body.Speed.Magnitude() > 0.5 {
+			g.Level.Particles.Add(NewDustEmitter(body.Center()[0], body.Object.Y+body.Object.H))
+		}
+	})
+
+}
+
+// NewChaser is an NPC that sees all around itself and doesn't give up on a chase easily.
+func NewChaser(level *Level) *GameObject {
+
+	ai := NewAIControlComponent(NewChaserBehavior())
+	ai.Perception.FieldOfView = math.Pi * 2
+	ai.Perception.VisionRange = 320
+	ai.Perception.MemoryFrames = 600
+	ai.UseFlowField = true
+
+	return NewNPC(level, ai)
+
+}
+
+// NewGuard is an NPC with a gun that runs on NewGuardBehavior.
+func NewGuard(level *Level) *GameObject {
+
+	guard := NewNPC(level, NewAIControlComponent(NewGuardBehavior()))
+
+	weapon := NewWeaponComponent()
+	weapon.FireRate = 45
+	guard.AddComponent(weapon)
+
+	return guard
+
+}
+
+// NewSkittish is an unarmed NPC that runs on NewSkittishBehavior.
+func NewSkittish(level *Level) *GameObject {
+	return NewNPC(level, NewAIControlComponent(NewSkittishBehavior()))
+}
+
+// NewTreeNPC is an armed NPC driven by the behavior tree in the given data file. If the file can't be loaded, the NPC
+// just stands around.
+func NewTreeNPC(level *Level, treePath string) *GameObject {
+
+	tree, err := GetBehaviorTree(treePath)
+	if err != nil {
+		log.Println(err)
+	}
+
+	npc := NewNPC(level, NewTreeAIControlComponent(tree))
+
+	weapon := NewWeaponComponent()
+	weapon.FireRate = 40
+	npc.AddComponent(weapon)
+
+	return npc
+
+}
+
+// NewChaserBehavior chases the player relentlessly.
+func NewChaserBehavior() *AIBehavior {
+	return NewAIBehavior(AIStateChase, NewChaseState())
+}
+
+// NewGuardBehavior patrols until it spots the player, then chases and shoots them, running away when it's hurt. Once
+// it's got away, it wanders around until it spots the player again.
+func NewGuardBehavior() *AIBehavior {
+
+	return NewAIBehavior(AIStatePatrol,
+		NewPatrolState(),
+		NewChaseState(),
+		NewAttackState(),
+		NewFleeState(),
+		NewWanderState(4),
+	).
+		// Not from Wander, or a hurt guard that's already got away would flee straight back into it
+		AddTransition(AIStatePatrol, AIStateFlee, HealthBelow(0.35)).
+		AddTransition(AIStateChase, AIStateFlee, HealthBelow(0.35)).
+		AddTransition(AIStateAttack, AIStateFlee, HealthBelow(0.35)).
+		AddTransition(AIStateFlee, AIStateWander, TargetFurtherThan(240)).
+		AddTransition(AIStatePatrol, AIStateChase, All(TargetCloserThan(160), TargetVisible())).
+		AddTransition(AIStateWander, AIStateChase, All(TargetCloserThan(160), TargetVisible())).
+		AddTransition(AIStateChase, AIStateAttack, All(TargetCloserThan(80), TargetVisible())).
+		AddTransition(AIStateAttack, AIStateChase, Any(TargetFurtherThan(112), TargetHidden())).
+		AddTransition(AIStateChase, AIStatePatrol, All(TargetHidden(), StateTimeOver(300)))
+
+}
+
+// NewSkittishBehavior wanders around and runs from the player when they get close.
+func NewSkittishBehavior() *AIBehavior {
+
+	return NewAIBehavior(AIStateWander,
+		NewIdleState(),
+		NewWanderState(6),
+		NewFleeState(),
+	).
+		AddTransition("", AIStateFlee, TargetCloserThan(96)).
+		AddTransition(AIStateFlee, AIStateIdle, TargetFurtherThan(192)).
+		AddTransition(AIStateIdle, AIStateWander, StateTimeOver(120))
+
+}
+
+// NewBullet is a bullet fired by the owner, which it can't hit.
+func NewBullet(level *Level, owner *GameObject, x, y float64, movementDirection vector.Vector) *GameObject {
 
 	bullet := NewGameObject(level)
 	body := NewBodyComponent(x, y, 4, 4, level.Space)
 	body.Speed = movementDirection.Scale(4)
+	body.Flying = true
+	body.HitsBodies = true
+	body.Owner = owner
+
+	body.OnBump = func(b *BodyComponent) {
 
-	body.OnBump = func(b *BodyComponent) { // Remove bullet and spawn explosion
 		level := b.GameObject.Level
+
+		// Hurt whoever was hit
+		if b.Bumped != nil {
+			if h := b.Bumped.GameObject.GetComponent(TypeHealthComponent); h != nil {
+				h.(*HealthComponent).Damage(1)
+			}
+			level.Camera.ShakeAt(b.Object.X, b.Object.Y, 0.05)
+			level.Remove(b.GameObject)
+			return
+		}
+
+		// Remove bullet, break cracked walls and spawn explosion
+		level.DestroyCellsInRect(b.Object.X+b.Speed[0], b.Object.Y+b.Speed[1], b.Object.W, b.Object.H)
+		level.Camera.ShakeAt(b.Object.X, b.Object.Y, 0.1)
 		level.Remove(b.GameObject)
-		level.Add(NewExplosionParticle(level, b.Object.X, b.Object.Y))
+		Explode(level, b.Object.X, b.Object.Y, b.Speed)
 	}
 
 	anim := NewAnimationComponent("assets/shot.json")
-	anim.Ase.Play("Anim")
+	anim.Play("Anim")
 	draw := NewDrawComponent(0, 0)
 	draw.Rotation, _ = vector.Vector{1, 0}.Angle(movementDirection)
 	draw.Rotation += math.Pi / 2
+	draw.HiddenInFog = true
+
+	glow := NewLightComponent(24)
+	glow.Light.Color = color.RGBA{255, 200, 120, 255}
 
 	bullet.AddComponent(
 		anim,
 		draw,
 		body,
+		glow,
 	)
 
 	return bullet
 
 }
 
-func NewExplosionParticle(level *Level, x, y float64) *GameObject {
-
-	particle := NewGameObject(level)
+// Explode shakes the camera and sets off an explosion, with sparks flying back against the direction whatever blew up
+// was going (or all around, if it's nil).
+func Explode(level *Level, x, y float64, direction vector.Vector) {
 
-	draw := NewDrawComponent(x-8, y-8)
+	level.Camera.ShakeAt(x, y, 0.15)
 
-	ds := NewDepthSortComponent(false)
-	ds.Depth = -1000000
+	explosion := NewParticleEmitter(x, y)
+	explosion.Sprite = GetParticleSprite("assets/small_explosion.json")
+	explosion.Burst = 1
+	explosion.SpeedMin, explosion.SpeedMax = 0, 0
+	explosion.LifetimeMin, explosion.LifetimeMax = 15, 15 // As long as the explosion's animation
+	explosion.EndColor = color.White
 
-	anim := NewAnimationComponent("assets/small_explosion.json")
-	anim.Ase.Play("Anim")
-	anim.OnAnimEnd = func(anim *AnimationComponent) {
-		draw.Visible = false
-		level.Remove(particle)
+	sparks := NewSparkEmitter(x, y, 0)
+	if direction != nil && direction.Magnitude() > 0 {
+		sparks.Angle = math.Atan2(-direction[1], -direction[0])
+	} else {
+		sparks.Spread = math.Pi * 2
 	}
 
-	particle.AddComponent(
-		ds,
-		anim,
-		draw,
-	)
+	level.Particles.Add(explosion, sparks)
+
+	flash := NewLight(x, y, 64)
+	flash.Color = color.RGBA{255, 150, 50, 255}
+	flash.Lifetime = 20
+	level.Lighting.Add(flash)
 
-	return particle
+}
+
+// NewSparkEmitter throws out a burst of glowing sparks in a cone around the angle.
+func NewSparkEmitter(x, y, angle float64) *ParticleEmitter {
+	sparks := NewParticleEmitter(x, y)
+	sparks.Burst = 8
+	sparks.Angle, sparks.Spread = angle, math.Pi*0.75
+	sparks.SpeedMin, sparks.SpeedMax = 1, 2.5
+	sparks.Drag = 0.1
+	sparks.LifetimeMin, sparks.LifetimeMax = 10, 20
+	sparks.StartColor = color.RGBA{255, 240, 150, 255}
+	sparks.EndColor = color.RGBA{255, 80, 0, 0}
+	sparks.Additive = true
+	return sparks
+}
+
+// NewMuzzleFlashEmitter is a quick flash of light out of the end of a gun pointing at the angle.
+func NewMuzzleFlashEmitter(x, y, angle float64) *ParticleEmitter {
+	flash := NewParticleEmitter(x, y)
+	flash.Burst = 4
+	flash.Angle, flash.Spread = angle, 0.6
+	flash.SpeedMin, flash.SpeedMax = 0.5, 1.5
+	flash.LifetimeMin, flash.LifetimeMax = 4, 8
+	flash.StartColor = color.White
+	flash.EndColor = color.RGBA{255, 200, 50, 0}
+	flash.StartSize, flash.EndSize = 2, 1
+	flash.Additive = true
+	return flash
+}
+
+// NewDustEmitter is a little puff of dust, like from a footstep.
+func NewDustEmitter(x, y float64) *ParticleEmitter {
+	dust := NewParticleEmitter(x, y)
+	dust.Burst = 3
+	dust.SpeedMin, dust.SpeedMax = 0.1, 0.4
+	dust.Gravity = -0.01 // Drifts up
+	dust.LifetimeMin, dust.LifetimeMax = 20, 30
+	dust.StartColor = color.RGBA{180, 170, 160, 150}
+	dust.EndColor = color.RGBA{180, 170, 160, 0}
+	dust.StartSize, dust.EndSize = 1, 2
+	return dust
+}
 
+// NewBloodEmitter splatters blood.
+func NewBloodEmitter(x, y float64) *ParticleEmitter {
+	blood := NewParticleEmitter(x, y)
+	blood.Burst = 10
+	blood.SpeedMin, blood.SpeedMax = 0.5, 2
+	blood.Gravity = 0.08
+	blood.Drag = 0.05
+	blood.LifetimeMin, blood.LifetimeMax = 20, 40
+	blood.StartColor = color.RGBA{170, 20, 30, 255}
+	blood.EndColor = color.RGBA{90, 10, 20, 0}
+	return blood
 }
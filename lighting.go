@@ -0,0 +1,156 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Light lights up the area around it. Lights with a Lifetime fade out over it and then go away.
+type Light struct {
+	X, Y     float64
+	Radius   float64
+	Color    color.Color // Tints the area the light falls on; nil just lights it
+	Flicker  float64     // How much the Radius wobbles from frame to frame, from 0 to 1
+	Lifetime int         // In frames; 0 lasts until it's removed
+	Age      int
+}
+
+func NewLight(x, y, radius float64) *Light {
+	return &Light{X: x, Y: y, Radius: radius}
+}
+
+// Strength returns how brightly the light's shining, from 0 to 1.
+func (light *Light) Strength() float64 {
+	if light.Lifetime <= 0 {
+		return 1
+	}
+	return math.Max(0, 1-float64(light.Age)/float64(light.Lifetime))
+}
+
+// Lighting darkens the Level with its Ambient color, leaving pools of light around each of its Lights.
+type Lighting struct {
+	Level    *Level
+	Ambient  color.Color
+	Lights   []*Light
+	darkness *ebiten.Image
+	falloff  *ebiten.Image
+}
+
+func NewLighting(level *Level) *Lighting {
+	return &Lighting{
+		Level:   level,
+		Ambient: color.RGBA{5, 4, 12, 220},
+	}
+}
+
+func (lighting *Lighting) Add(lights ...*Light) {
+	lighting.Lights = append(lighting.Lights, lights...)
+}
+
+func (lighting *Lighting) Remove(light *Light) {
+	for i, l := range lighting.Lights {
+		if l == light {
+			lighting.Lights = append(lighting.Lights[:i], lighting.Lights[i+1:]...)
+			return
+		}
+	}
+}
+
+// Update ages the Lights, dropping the ones that have burned out.
+func (lighting *Lighting) Update() {
+
+	lights := lighting.Lights[:0]
+
+	for _, light := range lighting.Lights {
+		light.Age++
+		if light.Lifetime <= 0 || light.Age < light.Lifetime {
+			lights = append(lights, light)
+		}
+	}
+
+	lighting.Lights = lights
+
+}
+
+// Draw darkens the screen, except around the Lights, and tints what the colored ones fall on.
+func (lighting *Lighting) Draw(screen *ebiten.Image) {
+
+	if lighting.falloff == nil {
+		lighting.falloff = newLightFalloff(64)
+	}
+
+	w, h := screen.Size()
+
+	if lighting.darkness == nil {
+		lighting.darkness, _ = ebiten.NewImage(w, h, ebiten.FilterNearest)
+	} else if dw, dh := lighting.darkness.Size(); dw != w || dh != h {
+		lighting.darkness.Dispose()
+		lighting.darkness, _ = ebiten.NewImage(w, h, ebiten.FilterNearest)
+	}
+
+	lighting.darkness.Fill(lighting.Ambient)
+
+	cam := lighting.Level.Camera
+	size, _ := lighting.falloff.Size()
+
+	for _, light := range lighting.Lights {
+
+		// Lights the player can't see don't show through the fog
+		if fog := lighting.Level.Fog; fog != nil && !fog.VisibleAt(light.X, light.Y) {
+			continue
+		}
+
+		radius := light.Radius * (1 + (rand.Float64()*2-1)*light.Flicker)
+
+		if !cam.InView(light.X-radius, light.Y-radius, radius*2, radius*2) {
+			continue
+		}
+
+		x, y := cam.WorldToScreen(light.X, light.Y)
+		scale := radius * 2 * cam.Zoom / float64(size)
+
+		geoM := ebiten.GeoM{}
+		geoM.Translate(-float64(size)/2, -float64(size)/2)
+		geoM.Scale(scale, scale)
+		geoM.Translate(x, y)
+
+		// Cut the light out of the darkness
+		opt := &ebiten.DrawImageOptions{GeoM: geoM, CompositeMode: ebiten.CompositeModeDestinationOut}
+		opt.ColorM.Scale(1, 1, 1, light.Strength())
+		lighting.darkness.DrawImage(lighting.falloff, opt)
+
+		if light.Color != nil {
+			r, g, b, a := colorToScale(light.Color)
+			opt := &ebiten.DrawImageOptions{GeoM: geoM, CompositeMode: ebiten.CompositeModeLighter}
+			opt.ColorM.Scale(r, g, b, a*light.Strength()*0.3)
+			screen.DrawImage(lighting.falloff, opt)
+		}
+
+	}
+
+	screen.DrawImage(lighting.darkness, nil)
+
+}
+
+// newLightFalloff makes a white circle that fades out from its middle to its edge.
+func newLightFalloff(size int) *ebiten.Image {
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	half := float64(size) / 2
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			d := math.Hypot(float64(x)+0.5-half, float64(y)+0.5-half) / half
+			t := math.Max(0, 1-d)
+			img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, uint8(255 * t * t * (3 - 2*t))})
+		}
+	}
+
+	falloff, _ := ebiten.NewImageFromImage(img, ebiten.FilterLinear)
+	return falloff
+
+}
@@ -2,11 +2,10 @@ package main
 
 import (
 	"errors"
-	"fmt"
+	"image/color"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/hajimehoshi/ebiten/inpututil"
 
@@ -14,11 +13,19 @@ import (
 )
 
 type Game struct {
-	Level         *Level
-	Width, Height int
-	DebugMode     bool
+	Level                       *Level
+	Width, Height               int // The internal resolution the Level's drawn at
+	DebugMode                   bool
+	OutsideWidth, OutsideHeight int // The size of the window, as last given to Layout
+	Renderer                    *Renderer
+	UI                          *UI
+	CRT                         bool
+	Floor                       int
 }
 
+// Resolutions are the internal resolutions F2 cycles through.
+var Resolutions = [][2]int{{640, 360}, {480, 270}, {320, 180}}
+
 func NewGame() *Game {
 
 	ebiten.SetWindowResizable(true)
@@ -27,18 +34,14 @@ func NewGame() *Game {
 	game := &Game{
 		Width:  640,
 		Height: 360,
+		Floor:  1,
 	}
 
-	game.Level = NewLevel(game)
+	ebiten.SetWindowSize(game.Width*2, game.Height*2)
 
-	// Debug FPS printing
-	go func() {
-		for {
-			fmt.Println(ebiten.CurrentFPS())
-			fmt.Println(ebiten.CurrentTPS())
-			time.Sleep(time.Second)
-		}
-	}()
+	game.Renderer = NewRenderer(game)
+	game.UI = NewUI(game)
+	game.Level = NewLevel(game)
 
 	return game
 
@@ -57,15 +60,84 @@ func (game *Game) Update(screen *ebiten.Image) error {
 	if inpututil.IsKeyJustPressed(ebiten.KeyF1) {
 		game.DebugMode = !game.DebugMode
 	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF2) {
+		for i, res := range Resolutions {
+			if res[0] == game.Width && res[1] == game.Height {
+				next := Resolutions[(i+1)%len(Resolutions)]
+				game.SetResolution(next[0], next[1])
+				break
+			}
+		}
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+		game.SetCRT(!game.CRT)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF4) {
+		game.Renderer.IntegerScaling = !game.Renderer.IntegerScaling
+	}
+
+	target := game.Renderer.BeginFrame()
 
-	game.Level.Update(screen)
+	game.Level.Update(target)
+
+	game.UI.Update()
+	game.UI.Draw(target)
+
+	game.Renderer.Present(screen)
 
 	return quit
 
 }
 
+// Layout gives ebiten's screen the size of the window, so the Renderer can do the scaling and letterboxing itself.
+// ebiten needs a screen at least 1x1, so a minimized window gets one that size.
 func (game *Game) Layout(w, h int) (int, int) {
-	return game.Width, game.Height
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	game.OutsideWidth, game.OutsideHeight = w, h
+	return w, h
+}
+
+// SetResolution changes the internal resolution the Level's drawn at.
+func (game *Game) SetResolution(w, h int) {
+	game.Width, game.Height = w, h
+}
+
+// SetCRT turns the scanlines, vignette and color grading of the CRT look on or off.
+func (game *Game) SetCRT(on bool) {
+	game.CRT = on
+	if on {
+		game.Renderer.Scanlines = 0.35
+		game.Renderer.Vignette = 0.6
+		game.Renderer.ColorGrading = NewColorGrade(0.02, 1.1, 1.15, color.RGBA{255, 245, 230, 255})
+	} else {
+		game.Renderer.Scanlines = 0
+		game.Renderer.Vignette = 0
+		game.Renderer.ColorGrading = nil
+	}
+}
+
+// LayoutScale returns how much the Renderer scales the Game's screen up to fit the window, and where in the window
+// the screen's top-left corner ends up.
+func (game *Game) LayoutScale() (float64, float64, float64) {
+	return game.Renderer.Placement(game.OutsideWidth, game.OutsideHeight)
+}
+
+// WindowToScreen converts a position in the window (like ebiten's cursor and touch positions) to a position on the
+// Game's screen.
+func (game *Game) WindowToScreen(x, y float64) (float64, float64) {
+	scale, ox, oy := game.LayoutScale()
+	return (x - ox) / scale, (y - oy) / scale
+}
+
+// ScreenToWindow converts a position on the Game's screen to a position in the window.
+func (game *Game) ScreenToWindow(x, y float64) (float64, float64) {
+	scale, ox, oy := game.LayoutScale()
+	return x*scale + ox, y*scale + oy
 }
 
 func main() {
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+// MinimapWidget shows the parts of the Level's map the player's explored, with markers for the player, the NPCs
+// they can see, and the exits (the doors between rooms). It sits in the corner of the screen, or covers the whole
+// screen once ToggleKey's pressed. Without fog of war, cells count as explored once the player's been within
+// RevealRadius cells of them.
+type MinimapWidget struct {
+	Placement    Placement
+	CellSize     float64
+	Fullscreen   bool
+	ToggleKey    ebiten.Key
+	RevealRadius int
+	FloorColor   color.RGBA
+	WallColor    color.RGBA
+	WaterColor   color.RGBA
+	PitColor     color.RGBA
+	ExitColor    color.Color
+	PlayerColor  color.Color
+	NPCColor     color.Color
+	explored     [][]bool
+	level        *Level
+	image        *ebiten.Image
+	pixels       []byte
+}
+
+func NewMinimapWidget() *MinimapWidget {
+	return &MinimapWidget{
+		Placement:    Placement{Anchor: AnchorTopRight, X: 8, Y: 8},
+		CellSize:     2,
+		ToggleKey:    ebiten.KeyM,
+		RevealRadius: 8,
+		FloorColor:   color.RGBA{90, 85, 110, 200},
+		WallColor:    color.RGBA{30, 26, 40, 200},
+		WaterColor:   color.RGBA{50, 90, 160, 200},
+		PitColor:     color.RGBA{10, 8, 16, 200},
+		ExitColor:    color.RGBA{230, 150, 60, 255},
+		PlayerColor:  color.RGBA{255, 230, 90, 255},
+		NPCColor:     color.RGBA{220, 60, 50, 255},
+	}
+}
+
+func (mm *MinimapWidget) Update(ui *UI) {
+
+	if inpututil.IsKeyJustPressed(mm.ToggleKey) {
+		mm.Fullscreen = !mm.Fullscreen
+	}
+
+	level := ui.Game.Level
+	if level == nil {
+		return
+	}
+
+	w, h := level.Map.Width, level.Map.Height
+
+	// A new Level starts out unexplored.
+	if level != mm.level || len(mm.explored) != h || (h > 0 && len(mm.explored[0]) != w) {
+		mm.level = level
+		mm.explored = make([][]bool, h)
+		for y := range mm.explored {
+			mm.explored[y] = make([]bool, w)
+		}
+	}
+
+	if level.Fog == nil {
+		mm.reveal(level)
+	}
+
+	if mm.image != nil {
+		if iw, ih := mm.image.Size(); iw != w || ih != h {
+			mm.image.Dispose()
+			mm.image = nil
+		}
+	}
+
+	if mm.image == nil {
+		mm.image, _ = ebiten.NewImage(w, h, ebiten.FilterNearest)
+		mm.pixels = make([]byte, w*h*4)
+	}
+
+	for y := 0; y < h; y++ {
+
+		for x := 0; x < w; x++ {
+
+			clr := color.RGBA{}
+
+			if mm.Explored(x, y) {
+				switch level.Map.Get(x, y) {
+				case WATER:
+					clr = mm.WaterColor
+				case PIT:
+					clr = mm.PitColor
+				default:
+					clr = mm.FloorColor
+					if level.CellType(x, y).Opaque {
+						clr = mm.WallColor
+					}
+				}
+			}
+
+			setPremultiplied(mm.pixels[(y*w+x)*4:], clr)
+
+		}
+
+	}
+
+	mm.image.ReplacePixels(mm.pixels)
+
+}
+
+// reveal marks the cells around the player as explored.
+func (mm *MinimapWidget) reveal(level *Level) {
+
+	if level.Player == nil {
+		return
+	}
+
+	b := level.Player.GetComponent(TypeBodyComponent)
+	if b == nil {
+		return
+	}
+
+	center := b.(*BodyComponent).Center()
+	px, py := level.CellAt(center[0], center[1])
+
+	for y := py - mm.RevealRadius; y <= py+mm.RevealRadius; y++ {
+		for x := px - mm.RevealRadius; x <= px+mm.RevealRadius; x++ {
+			if y >= 0 && y < len(mm.explored) && x >= 0 && x < len(mm.explored[y]) &&
+				math.Hypot(float64(x-px), float64(y-py)) <= float64(mm.RevealRadius) {
+				mm.explored[y][x] = true
+			}
+		}
+	}
+
+}
+
+// Explored returns if the player's explored the cell at x, y.
+func (mm *MinimapWidget) Explored(x, y int) bool {
+
+	if fog := mm.level.Fog; fog != nil {
+		return y >= 0 && y < len(fog.Explored) && x >= 0 && x < len(fog.Explored[y]) && fog.Explored[y][x]
+	}
+
+	return y >= 0 && y < len(mm.explored) && x >= 0 && x < len(mm.explored[y]) && mm.explored[y][x]
+
+}
+
+// seen returns if the player can see the cell at x, y right now.
+func (mm *MinimapWidget) seen(x, y int) bool {
+
+	if fog := mm.level.Fog; fog != nil {
+		return y >= 0 && y < len(fog.Visible) && x >= 0 && x < len(fog.Visible[y]) && fog.Visible[y][x]
+	}
+
+	if player := mm.level.Player; player != nil {
+		if b := player.GetComponent(TypeBodyComponent); b != nil {
+			center := b.(*BodyComponent).Center()
+			px, py := mm.level.CellAt(center[0], center[1])
+			return math.Hypot(float64(x-px), float64(y-py)) <= float64(mm.RevealRadius)
+		}
+	}
+
+	return false
+
+}
+
+func (mm *MinimapWidget) Draw(screen *ebiten.Image, ui *UI) {
+
+	level := ui.Game.Level
+	if level == nil || mm.image == nil || level != mm.level {
+		return
+	}
+
+	iw, ih := mm.image.Size()
+	cellSize := mm.CellSize
+	var x, y float64
+
+	if mm.Fullscreen {
+
+		screenW, screenH := ui.Size()
+		ebitenutil.DrawRect(screen, 0, 0, screenW, screenH, color.RGBA{10, 8, 16, 220})
+
+		// As big as fits with a margin, in whole pixels per cell
+		cellSize = math.Max(1, math.Floor(math.Min((screenW-32)/float64(iw), (screenH-32)/float64(ih))))
+		x, y = ui.Place(Placement{Anchor: AnchorCenter}, float64(iw)*cellSize, float64(ih)*cellSize)
+
+	} else {
+		x, y = ui.Place(mm.Placement, float64(iw)*cellSize, float64(ih)*cellSize)
+	}
+
+	x, y = math.Round(x), math.Round(y)
+
+	opt := &ebiten.DrawImageOptions{}
+	opt.GeoM.Scale(cellSize, cellSize)
+	opt.GeoM.Translate(x, y)
+	screen.DrawImage(mm.image, opt)
+
+	marker := func(cx, cy int, clr color.Color) {
+		ebitenutil.DrawRect(screen, x+float64(cx)*cellSize, y+float64(cy)*cellSize, cellSize, cellSize, clr)
+	}
+
+	for cy := 0; cy < ih; cy++ {
+		for cx := 0; cx < iw; cx++ {
+			if r := level.Map.Get(cx, cy); (r == DOOR_CLOSED || r == DOOR_OPEN) && mm.Explored(cx, cy) {
+				marker(cx, cy, mm.ExitColor)
+			}
+		}
+	}
+
+	for _, g := range level.GameObjects {
+
+		if g == level.Player || g.GetComponent(TypeAIControlComponent) == nil {
+			continue
+		}
+
+		if b := g.GetComponent(TypeBodyComponent); b != nil {
+			center := b.(*BodyComponent).Center()
+			if cx, cy := level.CellAt(center[0], center[1]); mm.seen(cx, cy) {
+				marker(cx, cy, mm.NPCColor)
+			}
+		}
+
+	}
+
+	if player := level.Player; player != nil {
+		if b := player.GetComponent(TypeBodyComponent); b != nil {
+			center := b.(*BodyComponent).Center()
+			px, py := level.CellAt(center[0], center[1])
+			marker(px, py, mm.PlayerColor)
+		}
+	}
+
+}
+
+// setPremultiplied writes the color into the first four bytes of pixels, premultiplying it for ReplacePixels.
+func setPremultiplied(pixels []byte, clr color.RGBA) {
+	a := uint16(clr.A)
+	pixels[0] = byte(uint16(clr.R) * a / 255)
+	pixels[1] = byte(uint16(clr.G) * a / 255)
+	pixels[2] = byte(uint16(clr.B) * a / 255)
+	pixels[3] = clr.A
+}
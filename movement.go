@@ -0,0 +1,57 @@
+package main
+
+import "github.com/kvartborg/vector"
+
+// Movement is the kinematic model BodyComponents with one move by. Each frame the body's speed first loses Friction,
+// then gains Accel in the direction of its Thrust, and is then capped to MaxSpeed. The friction's scaled by the
+// Friction of the CellType the body's standing on, so some surfaces are slipperier (or stickier) than others.
+type Movement struct {
+	Accel    float64
+	Friction float64
+	MaxSpeed float64
+}
+
+func NewMovement(accel, friction, maxSpeed float64) *Movement {
+	return &Movement{
+		Accel:    accel,
+		Friction: friction,
+		MaxSpeed: maxSpeed,
+	}
+}
+
+// NewWalkingMovement is the Movement the player and NPCs walk around with.
+func NewWalkingMovement() *Movement {
+	return NewMovement(0.5, 0.25, 2)
+}
+
+// Step returns the speed after one frame of moving with the given thrust (which is capped to a length of 1) on a
+// surface with the given friction modifier. The speed passed in isn't changed.
+func (m *Movement) Step(speed, thrust vector.Vector, surfaceFriction float64) vector.Vector {
+
+	speed = speed.Clone()
+	friction := m.Friction * surfaceFriction
+
+	if mag := speed.Magnitude(); mag <= friction {
+		speed = vector.Vector{0, 0}
+	} else {
+		speed.Scale((mag - friction) / mag)
+	}
+
+	if thrust.Magnitude() > 0 {
+
+		t := thrust.Clone()
+		if t.Magnitude() > 1 {
+			t.Unit()
+		}
+
+		speed.Add(t.Scale(m.Accel))
+
+		if mag := speed.Magnitude(); mag > m.MaxSpeed {
+			speed.Scale(m.MaxSpeed / mag)
+		}
+
+	}
+
+	return speed
+
+}
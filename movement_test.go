@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/kvartborg/vector"
+)
+
+// settle steps the movement with constant thrust until its speed stops changing, returning the speed it settles at.
+func settle(t *testing.T, m *Movement, thrust vector.Vector, surfaceFriction float64) float64 {
+
+	speed := vector.Vector{0, 0}
+
+	for i := 0; i < 1000; i++ {
+		next := m.Step(speed, thrust, surfaceFriction)
+		if vector.Sub(next, speed).Magnitude() < 1e-12 {
+			return speed.Magnitude()
+		}
+		speed = next
+	}
+
+	t.Fatalf("speed never settled; got to %v", speed)
+	return 0
+
+}
+
+func TestMovementTopSpeed(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		movement        *Movement
+		thrust          vector.Vector
+		surfaceFriction float64
+	}{
+		{"walking", NewWalkingMovement(), vector.Vector{1, 0}, 1},
+		{"walking diagonally", NewWalkingMovement(), vector.Vector{1, 1}, 1},
+		{"walking in water", NewWalkingMovement(), vector.Vector{0, -1}, CellTypes[WATER].Friction},
+		{"sprinting", NewMovement(1, 0.5, 4), vector.Vector{-1, 0}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := settle(t, test.movement, test.thrust, test.surfaceFriction); math.Abs(got-test.movement.MaxSpeed) > 1e-9 {
+				t.Errorf("top speed is %f, want MaxSpeed (%f)", got, test.movement.MaxSpeed)
+			}
+		})
+	}
+
+}
+
+func TestMovementStoppingDistance(t *testing.T) {
+
+	tests := []struct {
+		name            string
+		movement        *Movement
+		surfaceFriction float64
+		distance        float64 // How far the body goes after letting go of the thrust at top speed
+		frames          int     // How many frames it takes to stop
+	}{
+		// Losing 0.25 a frame from 2: 1.75 + 1.5 + ... + 0.25
+		{"floor", NewWalkingMovement(), 1, 7, 8},
+		// Losing 0.375 a frame from 2: 1.625 + 1.25 + 0.875 + 0.5 + 0.125
+		{"water", NewWalkingMovement(), CellTypes[WATER].Friction, 4.375, 6},
+	}
+
+	for _, test := range tests {
+
+		t.Run(test.name, func(t *testing.T) {
+
+			speed := vector.Vector{test.movement.MaxSpeed, 0}
+			distance := 0.0
+			frames := 0
+
+			for speed.Magnitude() > 0 {
+				speed = test.movement.Step(speed, vector.Vector{0, 0}, test.surfaceFriction)
+				distance += speed.Magnitude()
+				frames++
+				if frames > 1000 {
+					t.Fatalf("never stopped; still going at %v", speed)
+				}
+			}
+
+			if math.Abs(distance-test.distance) > 1e-9 {
+				t.Errorf("stopped after %f, want %f", distance, test.distance)
+			}
+
+			if frames != test.frames {
+				t.Errorf("took %d frames to stop, want %d", frames, test.frames)
+			}
+
+		})
+
+	}
+
+}
+
+func TestMovementStepLeavesSpeedAlone(t *testing.T) {
+
+	speed := vector.Vector{1, 1}
+	NewWalkingMovement().Step(speed, vector.Vector{1, 0}, 1)
+
+	if !speed.Equal(vector.Vector{1, 1}) {
+		t.Errorf("Step changed the speed passed in to %v", speed)
+	}
+
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"path/filepath"
+
+	"github.com/SolarLune/goaseprite"
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Particle is a single speck of an effect. They're plain values, kept together in their emitter.
+type Particle struct {
+	X, Y, VX, VY  float64
+	Age, Lifetime int
+}
+
+// ParticleSprite is a sprite sheet a particle plays through once over its lifetime.
+type ParticleSprite struct {
+	Image  *ebiten.Image
+	Frames []image.Rectangle
+}
+
+// NewParticleSprite reads the frames from an exported Aseprite JSON file.
+func NewParticleSprite(animPath string) *ParticleSprite {
+
+	file := goaseprite.Open(animPath)
+	sprite := &ParticleSprite{Image: GetImage(filepath.Join(filepath.Dir(animPath), file.ImagePath))}
+
+	for _, frame := range file.Frames {
+		x, y := int(frame.X), int(frame.Y)
+		sprite.Frames = append(sprite.Frames, image.Rect(x, y, x+int(file.FrameWidth), y+int(file.FrameHeight)))
+	}
+
+	return sprite
+
+}
+
+// ParticleEmitter spawns particles at its position. It lets out a Burst of them the first time it updates, and Rate
+// more every frame while it's Active. Each particle flies off at an angle within Spread of Angle, fading from
+// StartColor to EndColor (and from StartSize to EndSize) over its lifetime. Particles are solid squares, or animate
+// through the Sprite if the emitter has one.
+type ParticleEmitter struct {
+	X, Y                     float64
+	Burst                    int
+	Rate                     float64 // How many particles to spawn each frame while Active; fractions add up over frames
+	Active                   bool
+	Persistent               bool // If the emitter stays in its ParticleSystem once it's inactive and out of particles
+	Angle, Spread            float64
+	SpeedMin, SpeedMax       float64
+	Gravity                  float64 // Added to the particles' downward speed each frame
+	Drag                     float64 // How much of their speed the particles lose each frame, from 0 to 1
+	LifetimeMin, LifetimeMax int     // In frames
+	StartColor, EndColor     color.Color
+	StartSize, EndSize       float64
+	Sprite                   *ParticleSprite
+	Additive                 bool
+	Particles                []Particle
+	burst                    bool
+	pending                  float64
+}
+
+func NewParticleEmitter(x, y float64) *ParticleEmitter {
+	return &ParticleEmitter{
+		X:           x,
+		Y:           y,
+		Spread:      math.Pi * 2,
+		SpeedMin:    0.5,
+		SpeedMax:    1,
+		LifetimeMin: 20,
+		LifetimeMax: 30,
+		StartColor:  color.White,
+		EndColor:    color.Transparent,
+		StartSize:   1,
+		EndSize:     1,
+	}
+}
+
+// Emit spawns the given number of particles right away.
+func (e *ParticleEmitter) Emit(count int) {
+
+	for i := 0; i < count; i++ {
+
+		angle := e.Angle + (rand.Float64()-0.5)*e.Spread
+		speed := e.SpeedMin + rand.Float64()*(e.SpeedMax-e.SpeedMin)
+		lifetime := e.LifetimeMin
+		if e.LifetimeMax > e.LifetimeMin {
+			lifetime += rand.Intn(e.LifetimeMax - e.LifetimeMin + 1)
+		}
+
+		e.Particles = append(e.Particles, Particle{
+			X:        e.X,
+			Y:        e.Y,
+			VX:       math.Cos(angle) * speed,
+			VY:       math.Sin(angle) * speed,
+			Lifetime: lifetime,
+		})
+
+	}
+
+}
+
+func (e *ParticleEmitter) Update() {
+
+	if !e.burst {
+		e.Emit(e.Burst)
+		e.burst = true
+	}
+
+	if e.Active {
+		e.pending += e.Rate
+		count := int(e.pending)
+		e.pending -= float64(count)
+		e.Emit(count)
+	}
+
+	alive := e.Particles[:0]
+
+	for _, p := range e.Particles {
+
+		p.Age++
+		if p.Age >= p.Lifetime {
+			continue
+		}
+
+		p.VY += e.Gravity
+		p.VX *= 1 - e.Drag
+		p.VY *= 1 - e.Drag
+		p.X += p.VX
+		p.Y += p.VY
+
+		alive = append(alive, p)
+
+	}
+
+	e.Particles = alive
+
+}
+
+// Finished returns if the emitter's done: it's let out its burst, isn't spawning any more, and its particles are gone.
+func (e *ParticleEmitter) Finished() bool {
+	return e.burst && !e.Active && !e.Persistent && len(e.Particles) == 0
+}
+
+// ParticleSystem updates a Level's particle emitters, and draws all of their particles in as few batches as it can;
+// one for each sprite sheet and blend mode. Normal batches are drawn before additive ones, so glows light up whatever
+// they're over.
+type ParticleSystem struct {
+	Level      *Level
+	Emitters   []*ParticleEmitter
+	pixel      *ebiten.Image
+	batches    map[particleBatchKey]*particleBatch
+	batchOrder []particleBatchKey // The batches' keys, in the order they were first used, so they draw the same each frame
+}
+
+type particleBatchKey struct {
+	Image    *ebiten.Image
+	Additive bool
+}
+
+type particleBatch struct {
+	Vertices []ebiten.Vertex
+	Indices  []uint16
+}
+
+func NewParticleSystem(level *Level) *ParticleSystem {
+	return &ParticleSystem{
+		Level:   level,
+		batches: map[particleBatchKey]*particleBatch{},
+	}
+}
+
+func (ps *ParticleSystem) Add(emitters ...*ParticleEmitter) {
+	ps.Emitters = append(ps.Emitters, emitters...)
+}
+
+func (ps *ParticleSystem) Remove(emitter *ParticleEmitter) {
+	for i, e := range ps.Emitters {
+		if e == emitter {
+			ps.Emitters = append(ps.Emitters[:i], ps.Emitters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Update moves all of the particles along, and drops emitters once they're finished.
+func (ps *ParticleSystem) Update() {
+
+	emitters := ps.Emitters[:0]
+
+	for _, e := range ps.Emitters {
+		e.Update()
+		if !e.Finished() {
+			emitters = append(emitters, e)
+		}
+	}
+
+	ps.Emitters = emitters
+
+}
+
+func (ps *ParticleSystem) Draw(screen *ebiten.Image) {
+
+	if ps.pixel == nil {
+		ps.pixel, _ = ebiten.NewImage(1, 1, ebiten.FilterNearest)
+		ps.pixel.Fill(color.White)
+	}
+
+	cam := ps.Level.Camera
+	fog := ps.Level.Fog
+
+	for _, e := range ps.Emitters {
+
+		img := ps.pixel
+		if e.Sprite != nil {
+			img = e.Sprite.Image
+		}
+
+		key := particleBatchKey{Image: img, Additive: e.Additive}
+		batch, exists := ps.batches[key]
+		if !exists {
+			batch = &particleBatch{}
+			ps.batches[key] = batch
+			ps.batchOrder = append(ps.batchOrder, key)
+		}
+
+		sr, sg, sb, sa := colorToScale(e.StartColor)
+		er, eg, eb, ea := colorToScale(e.EndColor)
+
+		for _, p := range e.Particles {
+
+			t := float64(p.Age) / float64(p.Lifetime)
+
+			src := image.Rect(0, 0, 1, 1)
+			w := e.StartSize + (e.EndSize-e.StartSize)*t
+			h := w
+
+			if e.Sprite != nil && len(e.Sprite.Frames) > 0 {
+				src = e.Sprite.Frames[int(t*float64(len(e.Sprite.Frames)))]
+				w, h = float64(src.Dx())*w, float64(src.Dy())*h
+			}
+
+			if !cam.InView(p.X-w/2, p.Y-h/2, w, h) || (fog != nil && !fog.VisibleAt(p.X, p.Y)) {
+				continue
+			}
+
+			if len(batch.Vertices)+4 > math.MaxUint16 || len(batch.Indices)+6 > ebiten.MaxIndicesNum {
+				ps.flush(screen, key, batch)
+			}
+
+			x1, y1 := cam.WorldToScreen(p.X-w/2, p.Y-h/2)
+			x2, y2 := cam.WorldToScreen(p.X+w/2, p.Y+h/2)
+
+			r := float32(sr + (er-sr)*t)
+			g := float32(sg + (eg-sg)*t)
+			b := float32(sb + (eb-sb)*t)
+			a := float32(sa + (ea-sa)*t)
+
+			i := uint16(len(batch.Vertices))
+
+			batch.Vertices = append(batch.Vertices,
+				ebiten.Vertex{DstX: float32(x1), DstY: float32(y1), SrcX: float32(src.Min.X), SrcY: float32(src.Min.Y), ColorR: r, ColorG: g, ColorB: b, ColorA: a},
+				ebiten.Vertex{DstX: float32(x2), DstY: float32(y1), SrcX: float32(src.Max.X), SrcY: float32(src.Min.Y), ColorR: r, ColorG: g, ColorB: b, ColorA: a},
+				ebiten.Vertex{DstX: float32(x1), DstY: float32(y2), SrcX: float32(src.Min.X), SrcY: float32(src.Max.Y), ColorR: r, ColorG: g, ColorB: b, ColorA: a},
+				ebiten.Vertex{DstX: float32(x2), DstY: float32(y2), SrcX: float32(src.Max.X), SrcY: float32(src.Max.Y), ColorR: r, ColorG: g, ColorB: b, ColorA: a},
+			)
+
+			batch.Indices = append(batch.Indices, i, i+1, i+2, i+1, i+3, i+2)
+
+		}
+
+	}
+
+	for _, additive := range []bool{false, true} {
+		for _, key := range ps.batchOrder {
+			if key.Additive == additive {
+				ps.flush(screen, key, ps.batches[key])
+			}
+		}
+	}
+
+}
+
+func (ps *ParticleSystem) flush(screen *ebiten.Image, key particleBatchKey, batch *particleBatch) {
+
+	if len(batch.Indices) == 0 {
+		return
+	}
+
+	opt := &ebiten.DrawTrianglesOptions{}
+	if key.Additive {
+		opt.CompositeMode = ebiten.CompositeModeLighter
+	}
+
+	screen.DrawTriangles(batch.Vertices, batch.Indices, key.Image, opt)
+
+	batch.Vertices = batch.Vertices[:0]
+	batch.Indices = batch.Indices[:0]
+
+}
@@ -0,0 +1,57 @@
+package main
+
+// PathScheduler spreads path requests from NPCs out over time, so that no more than MaxPerTick paths get calculated
+// in a single frame; the rest wait their turn in the queue.
+type PathScheduler struct {
+	MaxPerTick int
+	queue      []*AIControlComponent
+	queued     map[*AIControlComponent]bool
+}
+
+func NewPathScheduler(maxPerTick int) *PathScheduler {
+	return &PathScheduler{
+		MaxPerTick: maxPerTick,
+		queue:      []*AIControlComponent{},
+		queued:     map[*AIControlComponent]bool{},
+	}
+}
+
+// Request queues the NPC to have its path recalculated. An NPC that's already queued keeps its place in line.
+func (ps *PathScheduler) Request(ai *AIControlComponent) {
+	if !ps.queued[ai] {
+		ps.queued[ai] = true
+		ps.queue = append(ps.queue, ai)
+	}
+}
+
+func (ps *PathScheduler) Cancel(ai *AIControlComponent) {
+
+	if !ps.queued[ai] {
+		return
+	}
+
+	delete(ps.queued, ai)
+
+	for i, q := range ps.queue {
+		if q == ai {
+			ps.queue = append(ps.queue[:i], ps.queue[i+1:]...)
+			break
+		}
+	}
+
+}
+
+func (ps *PathScheduler) Pending(ai *AIControlComponent) bool {
+	return ps.queued[ai]
+}
+
+func (ps *PathScheduler) Update() {
+
+	for i := 0; i < ps.MaxPerTick && len(ps.queue) > 0; i++ {
+		ai := ps.queue[0]
+		ps.queue = ps.queue[1:]
+		delete(ps.queued, ai)
+		ai.RecalculatePath()
+	}
+
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/kvartborg/vector"
+)
+
+// Perception is what an NPC can sense. It sees targets within VisionRange that are inside of a FieldOfView-wide cone
+// (in radians) pointed along the NPC's Facing, as long as there are no solid cells in the way; targets within
+// AwarenessRange are noticed no matter which way the NPC's facing. Once it loses sight of a target, it remembers where
+// it last saw it for MemoryFrames frames.
+type Perception struct {
+	VisionRange       float64
+	FieldOfView       float64
+	AwarenessRange    float64
+	MemoryFrames      int
+	CanSee            bool
+	LastKnownPosition vector.Vector
+	TimeSinceSeen     int
+}
+
+func NewPerception() *Perception {
+	return &Perception{
+		VisionRange:    192,
+		FieldOfView:    math.Pi * 2 / 3,
+		AwarenessRange: 32,
+		MemoryFrames:   180,
+	}
+}
+
+// Sense updates the Perception and the NPC's Target.
+func (p *Perception) Sense(ai *AIControlComponent) {
+
+	level := ai.GameObject.Level
+	pos := ai.Position()
+
+	p.CanSee = false
+
+	if target := level.Spatial.Nearest(pos[0], pos[1], p.VisionRange, TypePlayerControlComponent); target != nil {
+
+		targetPos := target.GetComponent(TypeBodyComponent).(*BodyComponent).Center()
+
+		if p.InView(pos, ai.Facing, targetPos) && level.LineOfSight(pos, targetPos) {
+			ai.Target = target
+			p.CanSee = true
+			p.LastKnownPosition = targetPos
+			p.TimeSinceSeen = 0
+		}
+
+	}
+
+	if !p.CanSee {
+
+		p.TimeSinceSeen++
+
+		if p.TimeSinceSeen > p.MemoryFrames {
+			ai.Target = nil
+			p.LastKnownPosition = nil
+		}
+
+	}
+
+}
+
+// InView returns if the target position is within range and inside of the vision cone looking along facing from
+// the position (ignoring walls).
+func (p *Perception) InView(pos, facing, target vector.Vector) bool {
+
+	diff := vector.Sub(target, pos)
+	dist := diff.Magnitude()
+
+	if dist <= p.AwarenessRange {
+		return true
+	}
+
+	if dist > p.VisionRange {
+		return false
+	}
+
+	if p.FieldOfView >= math.Pi*2 || facing.Magnitude() == 0 {
+		return true
+	}
+
+	angle := math.Abs(math.Atan2(diff[1], diff[0]) - math.Atan2(facing[1], facing[0]))
+	if angle > math.Pi {
+		angle = math.Pi*2 - angle
+	}
+
+	return angle <= p.FieldOfView/2
+
+}
+
+func (p *Perception) DrawDebug(screen *ebiten.Image, ai *AIControlComponent) {
+
+	level := ai.GameObject.Level
+	pos := ai.Position()
+	cam := level.Camera
+
+	coneColor := color.RGBA{255, 255, 255, 128}
+	if p.CanSee {
+		coneColor = color.RGBA{255, 0, 0, 192}
+	}
+
+	facingAngle := math.Atan2(ai.Facing[1], ai.Facing[0])
+
+	for _, side := range []float64{-1, 1} {
+		a := facingAngle + side*p.FieldOfView/2
+		cam.DrawLine(screen, pos[0], pos[1], pos[0]+math.Cos(a)*p.VisionRange, pos[1]+math.Sin(a)*p.VisionRange, coneColor)
+	}
+
+	if p.LastKnownPosition != nil {
+		cam.DrawRect(screen, p.LastKnownPosition[0]-2, p.LastKnownPosition[1]-2, 4, 4, color.RGBA{255, 128, 0, 255})
+	}
+
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Renderer draws the Game at its internal resolution (Game.Width x Game.Height) onto an offscreen Target, and then
+// presents that on the screen: scaled up as far as it fits (by whole numbers with IntegerScaling, so pixels stay
+// square), centered, and letterboxed with the Border color. Color grading goes on as it's scaled up, and scanlines
+// and a vignette go over the top.
+type Renderer struct {
+	Game           *Game
+	Target         *ebiten.Image
+	IntegerScaling bool
+	Border         color.Color
+	ColorGrading   *ebiten.ColorM // nil leaves the colors as they are
+	Scanlines      float64        // How dark the gaps between the Target's rows are, from 0 (off) to 1
+	Vignette       float64        // How dark the corners are, from 0 (off) to 1
+	scanlines      *ebiten.Image
+	vignette       *ebiten.Image
+}
+
+func NewRenderer(game *Game) *Renderer {
+	return &Renderer{
+		Game:           game,
+		IntegerScaling: true,
+		Border:         color.Black,
+	}
+}
+
+// BeginFrame returns the Target to draw the frame on, (re)making it if the Game's resolution has changed.
+func (r *Renderer) BeginFrame() *ebiten.Image {
+
+	w, h := r.Game.Width, r.Game.Height
+
+	if r.Target != nil {
+		if tw, th := r.Target.Size(); tw != w || th != h {
+			r.Target.Dispose()
+			r.Target = nil
+		}
+	}
+
+	if r.Target == nil {
+		r.Target, _ = ebiten.NewImage(w, h, ebiten.FilterNearest)
+	}
+
+	r.Target.Clear()
+
+	return r.Target
+
+}
+
+// Placement returns how much the Target's scaled up to fit a destination of the given size, and where its top-left
+// corner goes.
+func (r *Renderer) Placement(dstW, dstH int) (float64, float64, float64) {
+
+	if dstW <= 0 || dstH <= 0 {
+		return 1, 0, 0
+	}
+
+	scale := math.Min(float64(dstW)/float64(r.Game.Width), float64(dstH)/float64(r.Game.Height))
+
+	// Too small a window to scale up by a whole number just gets the screen shrunk down to fit.
+	if r.IntegerScaling && scale >= 1 {
+		scale = math.Floor(scale)
+	}
+
+	x := math.Floor((float64(dstW) - float64(r.Game.Width)*scale) / 2)
+	y := math.Floor((float64(dstH) - float64(r.Game.Height)*scale) / 2)
+
+	return scale, x, y
+
+}
+
+// Present draws the Target onto dst with all of the post-processing. dst can be any image, not just the screen, so a
+// frame can be rendered offscreen and compared pixel by pixel.
+func (r *Renderer) Present(dst *ebiten.Image) {
+
+	if r.Target == nil {
+		return
+	}
+
+	dstW, dstH := dst.Size()
+	scale, x, y := r.Placement(dstW, dstH)
+	w, h := float64(r.Game.Width)*scale, float64(r.Game.Height)*scale
+
+	dst.Fill(r.Border)
+
+	opt := &ebiten.DrawImageOptions{}
+	opt.GeoM.Scale(scale, scale)
+	opt.GeoM.Translate(x, y)
+	if r.ColorGrading != nil {
+		opt.ColorM = *r.ColorGrading
+	}
+	dst.DrawImage(r.Target, opt)
+
+	if r.Scanlines > 0 && scale >= 2 {
+		r.drawScanlines(dst, scale, x, y, w, h)
+	}
+
+	if r.Vignette > 0 {
+
+		if r.vignette == nil {
+			r.vignette = newVignette(64)
+		}
+
+		size, _ := r.vignette.Size()
+		opt := &ebiten.DrawImageOptions{}
+		opt.GeoM.Scale(w/float64(size), h/float64(size))
+		opt.GeoM.Translate(x, y)
+		opt.ColorM.Scale(1, 1, 1, r.Vignette)
+		dst.DrawImage(r.vignette, opt)
+
+	}
+
+}
+
+// drawScanlines darkens the bottom third of each of the Target's rows, as they're scaled up on dst.
+func (r *Renderer) drawScanlines(dst *ebiten.Image, scale, x, y, w, h float64) {
+
+	rowHeight := int(math.Round(scale))
+
+	if r.scanlines != nil {
+		if _, sh := r.scanlines.Size(); sh != rowHeight {
+			r.scanlines.Dispose()
+			r.scanlines = nil
+		}
+	}
+
+	if r.scanlines == nil {
+		pattern := image.NewNRGBA(image.Rect(0, 0, 1, rowHeight))
+		for py := rowHeight - int(math.Ceil(float64(rowHeight)/3)); py < rowHeight; py++ {
+			pattern.SetNRGBA(0, py, color.NRGBA{0, 0, 0, 255})
+		}
+		r.scanlines, _ = ebiten.NewImageFromImage(pattern, ebiten.FilterNearest)
+	}
+
+	// The pattern's repeated down the whole screen rather than drawn once per row.
+	sw, sh := float32(w), float32(h)
+	vertex := func(dx, dy float32) ebiten.Vertex {
+		return ebiten.Vertex{
+			DstX: float32(x) + dx, DstY: float32(y) + dy,
+			SrcX: dx / sw, SrcY: dy * float32(rowHeight) / float32(scale),
+			ColorR: 1, ColorG: 1, ColorB: 1, ColorA: float32(r.Scanlines),
+		}
+	}
+
+	vertices := []ebiten.Vertex{vertex(0, 0), vertex(sw, 0), vertex(0, sh), vertex(sw, sh)}
+	dst.DrawTriangles(vertices, []uint16{0, 1, 2, 1, 3, 2}, r.scanlines, &ebiten.DrawTrianglesOptions{Address: ebiten.AddressRepeat})
+
+}
+
+// NewColorGrade makes a color matrix that adjusts the brightness (added to each channel), contrast (1 leaves it as
+// it is) and saturation (1 leaves it as it is) of a frame, and then tints it.
+func NewColorGrade(brightness, contrast, saturation float64, tint color.Color) *ebiten.ColorM {
+
+	grade := ebiten.ColorM{}
+	grade.ChangeHSV(0, saturation, 1)
+	grade.Scale(contrast, contrast, contrast, 1)
+	offset := (1-contrast)/2 + brightness
+	grade.Translate(offset, offset, offset, 0)
+
+	if tint != nil {
+		r, g, b, _ := colorToScale(tint)
+		grade.Scale(r, g, b, 1)
+	}
+
+	return &grade
+
+}
+
+// newVignette makes an image that's clear in the middle and darkens towards the edges and corners.
+func newVignette(size int) *ebiten.Image {
+
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	half := float64(size) / 2
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			d := math.Hypot(float64(x)+0.5-half, float64(y)+0.5-half) / (half * math.Sqrt2)
+			t := math.Max(0, math.Min(1, (d-0.5)/0.5))
+			img.SetNRGBA(x, y, color.NRGBA{0, 0, 0, uint8(255 * t * t)})
+		}
+	}
+
+	vignette, _ := ebiten.NewImageFromImage(img, ebiten.FilterLinear)
+	return vignette
+
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+	"os"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// pixelTestGame runs the tests from inside Ebiten's main loop, since images can't be read back outside of it.
+type pixelTestGame struct {
+	m    *testing.M
+	code int
+}
+
+var errTestsDone = errors.New("tests done")
+
+func (g *pixelTestGame) Update(screen *ebiten.Image) error {
+	g.code = g.m.Run()
+	return errTestsDone
+}
+
+func (g *pixelTestGame) Layout(w, h int) (int, int) { return 320, 240 }
+
+func TestMain(m *testing.M) {
+
+	game := &pixelTestGame{m: m}
+
+	if err := ebiten.RunGame(game); err != nil && err != errTestsDone {
+		panic(err)
+	}
+
+	os.Exit(game.code)
+
+}
+
+var (
+	testRed    = color.RGBA{255, 0, 0, 255}
+	testGreen  = color.RGBA{0, 255, 0, 255}
+	testBorder = color.RGBA{30, 60, 90, 255}
+)
+
+// newTestRenderer makes a Renderer for a Game of the given size, with a Target that's green apart from a red top-left
+// pixel.
+func newTestRenderer(w, h int) *Renderer {
+
+	r := NewRenderer(&Game{Width: w, Height: h})
+	r.Border = testBorder
+
+	target := r.BeginFrame()
+	target.Fill(testGreen)
+	target.Set(0, 0, testRed)
+
+	return r
+
+}
+
+// present draws the Renderer's Target onto a fresh image of the given size.
+func present(r *Renderer, w, h int) *ebiten.Image {
+	dst, _ := ebiten.NewImage(w, h, ebiten.FilterNearest)
+	r.Present(dst)
+	return dst
+}
+
+func checkPixels(t *testing.T, img *ebiten.Image, want map[[2]int]color.RGBA) {
+	t.Helper()
+	for pos, clr := range want {
+		if got := color.RGBAModel.Convert(img.At(pos[0], pos[1])).(color.RGBA); got != clr {
+			t.Errorf("pixel %v is %v, want %v", pos, got, clr)
+		}
+	}
+}
+
+func TestPlacement(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		integerScaling bool
+		dstW, dstH     int
+		scale, x, y    float64
+	}{
+		{"exact fit", true, 640, 360, 2, 0, 0},
+		{"letterboxed", true, 700, 400, 2, 30, 20},
+		{"rounded down to a whole number", true, 1000, 400, 2, 180, 20},
+		{"smooth scaling", false, 480, 360, 1.5, 0, 45},
+		{"window too small", true, 160, 90, 0.5, 0, 0},
+		{"minimized window", true, 1, 1, 1.0 / 320, 0, 0}, // Game.Layout never gives a screen smaller than 1x1
+	}
+
+	for _, test := range tests {
+		r := NewRenderer(&Game{Width: 320, Height: 180})
+		r.IntegerScaling = test.integerScaling
+		if scale, x, y := r.Placement(test.dstW, test.dstH); scale != test.scale || x != test.x || y != test.y {
+			t.Errorf("%s: got scale %f at %f, %f, want scale %f at %f, %f", test.name, scale, x, y, test.scale, test.x, test.y)
+		}
+	}
+
+}
+
+func TestPresentPlacement(t *testing.T) {
+
+	// A 4x2 Target fits a 10x6 destination twice over, one pixel in from the top-left.
+	checkPixels(t, present(newTestRenderer(4, 2), 10, 6), map[[2]int]color.RGBA{
+		{0, 0}: testBorder,
+		{1, 1}: testRed,
+		{2, 2}: testRed,
+		{3, 1}: testGreen,
+		{1, 3}: testGreen,
+		{8, 4}: testGreen,
+		{9, 4}: testBorder,
+		{8, 5}: testBorder,
+	})
+
+}
+
+func TestPresentBorder(t *testing.T) {
+
+	// Pillarboxed: a 4x2 Target scaled up twice in a 12x4 destination leaves two columns of border on either side.
+	checkPixels(t, present(newTestRenderer(4, 2), 12, 4), map[[2]int]color.RGBA{
+		{0, 0}:  testBorder,
+		{1, 3}:  testBorder,
+		{2, 0}:  testRed,
+		{9, 3}:  testGreen,
+		{10, 0}: testBorder,
+		{11, 3}: testBorder,
+	})
+
+}
+
+func TestPresentScanlines(t *testing.T) {
+
+	black := color.RGBA{0, 0, 0, 255}
+
+	// A 2x2 Target scaled up three times over: with scanlines, the bottom row of each of its rows goes dark.
+	r := newTestRenderer(2, 2)
+
+	checkPixels(t, present(r, 6, 6), map[[2]int]color.RGBA{
+		{3, 1}: testGreen,
+		{3, 2}: testGreen,
+		{3, 5}: testGreen,
+	})
+
+	r.Scanlines = 1
+
+	checkPixels(t, present(r, 6, 6), map[[2]int]color.RGBA{
+		{0, 0}: testRed,
+		{0, 1}: testRed,
+		{0, 2}: black,
+		{3, 1}: testGreen,
+		{3, 2}: black,
+		{3, 3}: testGreen,
+		{3, 5}: black,
+	})
+
+}
+
+func TestLayoutMinimized(t *testing.T) {
+	if w, h := (&Game{}).Layout(0, 0); w != 1 || h != 1 {
+		t.Errorf("a minimized window got a %dx%d screen, want 1x1", w, h)
+	}
+}
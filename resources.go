@@ -1,12 +1,22 @@
 package main
 
 import (
+	"log"
+
 	"github.com/hajimehoshi/ebiten"
 	"github.com/hajimehoshi/ebiten/ebitenutil"
 )
 
 var ImageResources = map[string]*ebiten.Image{}
 
+var BehaviorTreeResources = map[string]*BTSpec{}
+
+var SliceResources = map[string]map[string]*Slice{}
+
+var ParticleSpriteResources = map[string]*ParticleSprite{}
+
+var FontResources = map[string]*BitmapFont{}
+
 func GetImage(filepath string) *ebiten.Image {
 
 	res, exists := ImageResources[filepath]
@@ -19,3 +29,77 @@ func GetImage(filepath string) *ebiten.Image {
 	return res
 
 }
+
+// GetSlices returns the slices from the Aseprite JSON file at the given path. If the file can't be read, it logs the
+// error and returns no slices.
+func GetSlices(filepath string) map[string]*Slice {
+
+	res, exists := SliceResources[filepath]
+
+	if !exists {
+		var err error
+		if res, err = LoadSlices(filepath); err != nil {
+			log.Println(err)
+			res = map[string]*Slice{}
+		}
+		SliceResources[filepath] = res
+	}
+
+	return res
+
+}
+
+// GetParticleSprite returns the particle sprite from the Aseprite JSON file at the given path.
+func GetParticleSprite(filepath string) *ParticleSprite {
+
+	res, exists := ParticleSpriteResources[filepath]
+
+	if !exists {
+		res = NewParticleSprite(filepath)
+		ParticleSpriteResources[filepath] = res
+	}
+
+	return res
+
+}
+
+// GetFont returns the bitmap font described by the JSON file at the given path. If the file can't be read, it logs
+// the error and returns nil.
+func GetFont(filepath string) *BitmapFont {
+
+	res, exists := FontResources[filepath]
+
+	if !exists {
+		var err error
+		if res, err = LoadBitmapFont(filepath); err != nil {
+			log.Println(err)
+			return nil
+		}
+		FontResources[filepath] = res
+	}
+
+	return res
+
+}
+
+// GetBehaviorTree builds a new BehaviorTree from the data file at the given path. The file's only read once.
+func GetBehaviorTree(filepath string) (*BehaviorTree, error) {
+
+	spec, exists := BehaviorTreeResources[filepath]
+
+	if !exists {
+		var err error
+		if spec, err = LoadBTSpec(filepath); err != nil {
+			return nil, err
+		}
+		BehaviorTreeResources[filepath] = spec
+	}
+
+	root, err := spec.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBehaviorTree(root), nil
+
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+
+	"github.com/kvartborg/vector"
+)
+
+// Slice names artists can use in Aseprite files to set up a GameObject's collision and attachment points.
+const (
+	SliceHitbox  = "hitbox"  // The size of the BodyComponent, and where it sits in the sprite
+	SliceHurtbox = "hurtbox" // The area of the sprite that can be hurt
+	SliceMuzzle  = "muzzle"  // Where bullets come out; the slice's pivot if it has one, or its center otherwise
+)
+
+// Slice is a named rectangle from an Aseprite file. Each key sets the rectangle from its frame on, until the next key.
+type Slice struct {
+	Name string
+	Keys []SliceKey
+}
+
+type SliceKey struct {
+	Frame  int32
+	Bounds struct {
+		X, Y, W, H float64
+	}
+	Pivot *struct {
+		X, Y float64
+	}
+}
+
+// Point returns the key's pivot, or the center of its bounds if it doesn't have one.
+func (key *SliceKey) Point() (float64, float64) {
+	if key.Pivot != nil {
+		return key.Bounds.X + key.Pivot.X, key.Bounds.Y + key.Pivot.Y
+	}
+	return key.Bounds.X + key.Bounds.W/2, key.Bounds.Y + key.Bounds.H/2
+}
+
+// At returns the key in effect on the given frame, or nil if the slice doesn't start until later.
+func (slice *Slice) At(frame int32) *SliceKey {
+
+	var found *SliceKey
+
+	for i := range slice.Keys {
+		if slice.Keys[i].Frame <= frame && (found == nil || slice.Keys[i].Frame > found.Frame) {
+			found = &slice.Keys[i]
+		}
+	}
+
+	return found
+
+}
+
+// LoadSlices reads the slices out of an exported Aseprite JSON file, keyed by name.
+func LoadSlices(jsonPath string) (map[string]*Slice, error) {
+
+	data, err := ioutil.ReadFile(getPath(jsonPath))
+	if err != nil {
+		return nil, err
+	}
+
+	file := struct {
+		Meta struct {
+			Slices []*Slice
+		}
+	}{}
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	slices := map[string]*Slice{}
+	for _, s := range file.Meta.Slices {
+		slices[s.Name] = s
+	}
+
+	return slices, nil
+
+}
+
+// FitToSlices resizes the GameObject's BodyComponent to the hitbox slice on the first frame of its animation (keeping
+// the body's center where it was), and offsets its DrawComponent so the sprite lines up with the body.
+func FitToSlices(g *GameObject) {
+
+	a, b := g.GetComponent(TypeAnimationComponent), g.GetComponent(TypeBodyComponent)
+	if a == nil || b == nil {
+		return
+	}
+
+	anim, body := a.(*AnimationComponent), b.(*BodyComponent)
+
+	slice, exists := anim.Slices[SliceHitbox]
+	if !exists {
+		return
+	}
+
+	key := slice.At(0)
+	if key == nil {
+		return
+	}
+
+	center := body.Center()
+	body.Object.W, body.Object.H = key.Bounds.W, key.Bounds.H
+	body.Object.X, body.Object.Y = center[0]-key.Bounds.W/2, center[1]-key.Bounds.H/2
+	body.Object.Update()
+
+	if d := g.GetComponent(TypeDrawComponent); d != nil {
+		draw := d.(*DrawComponent)
+		draw.Offset[0] = float64(anim.Ase.FrameWidth)/2 - (key.Bounds.X + key.Bounds.W/2)
+		draw.Offset[1] = float64(anim.Ase.FrameHeight)/2 - (key.Bounds.Y + key.Bounds.H/2)
+	}
+
+}
+
+// slicePoint turns a point on the animation's frame into an offset from the center of the GameObject's body, going by
+// how the DrawComponent places (and flips) the sprite.
+func slicePoint(g *GameObject, anim *AnimationComponent, x, y float64) (float64, float64) {
+
+	x -= float64(anim.Ase.FrameWidth) / 2
+	y -= float64(anim.Ase.FrameHeight) / 2
+
+	if d := g.GetComponent(TypeDrawComponent); d != nil {
+		draw := d.(*DrawComponent)
+		if draw.FlipHorizontal {
+			x = -x
+		}
+		x += draw.Offset[0]
+		y += draw.Offset[1]
+	}
+
+	return x, y
+
+}
+
+// applySlices updates the hurtbox of the GameObject's BodyComponent and the SpawnOffset of its WeaponComponent from
+// the slices on the animation's current frame.
+func (a *AnimationComponent) applySlices() {
+
+	if len(a.Slices) == 0 {
+		return
+	}
+
+	frame := a.Ase.CurrentFrame
+
+	if b := a.GameObject.GetComponent(TypeBodyComponent); b != nil {
+
+		body := b.(*BodyComponent)
+		body.Hurtbox = nil
+
+		if slice, exists := a.Slices[SliceHurtbox]; exists {
+			if key := slice.At(frame); key != nil {
+				x1, y1 := slicePoint(a.GameObject, a, key.Bounds.X, key.Bounds.Y)
+				x2, y2 := slicePoint(a.GameObject, a, key.Bounds.X+key.Bounds.W, key.Bounds.Y+key.Bounds.H)
+				body.Hurtbox = &Rect{X: math.Min(x1, x2), Y: math.Min(y1, y2), W: key.Bounds.W, H: key.Bounds.H}
+			}
+		}
+
+	}
+
+	if w := a.GameObject.GetComponent(TypeWeaponComponent); w != nil {
+		if slice, exists := a.Slices[SliceMuzzle]; exists {
+			if key := slice.At(frame); key != nil {
+				px, py := key.Point()
+				x, y := slicePoint(a.GameObject, a, px, py)
+				w.(*WeaponComponent).SpawnOffset = vector.Vector{x, y}
+			}
+		}
+	}
+
+}
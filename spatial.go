@@ -0,0 +1,161 @@
+package main
+
+import (
+	"math"
+)
+
+// SpatialHash buckets GameObjects by position so nearby GameObjects can be found without scanning the whole Level.
+// BodyComponents keep their GameObject's position in the Level's SpatialHash current as they move.
+type SpatialHash struct {
+	CellSize float64
+	buckets  map[[2]int][]*GameObject
+	entries  map[*GameObject]spatialEntry
+}
+
+type spatialEntry struct {
+	Bucket [2]int
+	X, Y   float64
+}
+
+func NewSpatialHash(cellSize float64) *SpatialHash {
+	return &SpatialHash{
+		CellSize: cellSize,
+		buckets:  map[[2]int][]*GameObject{},
+		entries:  map[*GameObject]spatialEntry{},
+	}
+}
+
+func (sh *SpatialHash) bucketAt(x, y float64) [2]int {
+	return [2]int{int(math.Floor(x / sh.CellSize)), int(math.Floor(y / sh.CellSize))}
+}
+
+// Update sets the GameObject's position in the SpatialHash, adding it if it isn't in already.
+func (sh *SpatialHash) Update(g *GameObject, x, y float64) {
+
+	bucket := sh.bucketAt(x, y)
+
+	if entry, exists := sh.entries[g]; exists && entry.Bucket != bucket {
+		sh.removeFromBucket(g, entry.Bucket)
+		sh.buckets[bucket] = append(sh.buckets[bucket], g)
+	} else if !exists {
+		sh.buckets[bucket] = append(sh.buckets[bucket], g)
+	}
+
+	sh.entries[g] = spatialEntry{Bucket: bucket, X: x, Y: y}
+
+}
+
+func (sh *SpatialHash) Remove(g *GameObject) {
+	if entry, exists := sh.entries[g]; exists {
+		sh.removeFromBucket(g, entry.Bucket)
+		delete(sh.entries, g)
+	}
+}
+
+func (sh *SpatialHash) removeFromBucket(g *GameObject, bucket [2]int) {
+
+	list := sh.buckets[bucket]
+
+	for i, other := range list {
+		if other == g {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+
+	if len(list) == 0 {
+		delete(sh.buckets, bucket)
+	} else {
+		sh.buckets[bucket] = list
+	}
+
+}
+
+// Position returns the position the GameObject was last updated with, and whether it's in the SpatialHash at all.
+func (sh *SpatialHash) Position(g *GameObject) (float64, float64, bool) {
+	entry, exists := sh.entries[g]
+	return entry.X, entry.Y, exists
+}
+
+// QueryRect returns the GameObjects positioned inside of the rectangle.
+func (sh *SpatialHash) QueryRect(x, y, w, h float64) []*GameObject {
+
+	found := []*GameObject{}
+
+	start := sh.bucketAt(x, y)
+	end := sh.bucketAt(x+w, y+h)
+
+	for by := start[1]; by <= end[1]; by++ {
+		for bx := start[0]; bx <= end[0]; bx++ {
+			for _, g := range sh.buckets[[2]int{bx, by}] {
+				entry := sh.entries[g]
+				if entry.X >= x && entry.Y >= y && entry.X <= x+w && entry.Y <= y+h {
+					found = append(found, g)
+				}
+			}
+		}
+	}
+
+	return found
+
+}
+
+// QueryRadius returns the GameObjects positioned within the radius around x, y.
+func (sh *SpatialHash) QueryRadius(x, y, radius float64) []*GameObject {
+
+	found := []*GameObject{}
+
+	for _, g := range sh.QueryRect(x-radius, y-radius, radius*2, radius*2) {
+		entry := sh.entries[g]
+		if math.Hypot(entry.X-x, entry.Y-y) <= radius {
+			found = append(found, g)
+		}
+	}
+
+	return found
+
+}
+
+// Nearest returns the closest GameObject within maxDistance of x, y that has the given component, or nil if there's
+// none. Buckets are searched in rings moving outwards, so close hits return early.
+func (sh *SpatialHash) Nearest(x, y, maxDistance float64, componentTypeConstant string) *GameObject {
+
+	var nearest *GameObject
+	nearestDist := maxDistance
+
+	center := sh.bucketAt(x, y)
+	maxRing := int(math.Ceil(maxDistance/sh.CellSize)) + 1
+
+	for ring := 0; ring <= maxRing; ring++ {
+
+		// Nothing in this ring or further out can beat what we've found.
+		if nearest != nil && float64(ring-1)*sh.CellSize > nearestDist {
+			break
+		}
+
+		for by := center[1] - ring; by <= center[1]+ring; by++ {
+
+			for bx := center[0] - ring; bx <= center[0]+ring; bx++ {
+
+				// Only the outline of the ring; the inside was already searched.
+				if by != center[1]-ring && by != center[1]+ring && bx != center[0]-ring && bx != center[0]+ring {
+					continue
+				}
+
+				for _, g := range sh.buckets[[2]int{bx, by}] {
+					entry := sh.entries[g]
+					if dist := math.Hypot(entry.X-x, entry.Y-y); dist <= nearestDist && g.GetComponent(componentTypeConstant) != nil {
+						nearest = g
+						nearestDist = dist
+					}
+				}
+
+			}
+
+		}
+
+	}
+
+	return nearest
+
+}
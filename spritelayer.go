@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten"
+	"github.com/kvartborg/vector"
+)
+
+// SpriteLayer is an extra sprite a DrawComponent draws along with its GameObject's own, like a drop shadow under it
+// or a held weapon over it. It's centered on the GameObject's body (or the DrawComponent's Offset without one), plus
+// its own Offset, and sorted on its render Layer with DepthBias added to the GameObject's depth.
+type SpriteLayer struct {
+	Name      string
+	Animation *AnimationComponent // Played along with the DrawComponent; it shouldn't also be added to the GameObject
+	Offset    vector.Vector
+	Layer     int     // The render layer it's drawn on; RenderLayerGameObject puts it on the GameObject's own
+	DepthBias float64 // Below 0 sorts under the GameObject's own sprite; 0 and up sorts over it
+	Flip      bool    // If the layer flips (and mirrors its Offset) with the DrawComponent
+	Alpha     float64
+	Visible   bool
+}
+
+func NewSpriteLayer(name, animPath string, offsetX, offsetY, depthBias float64) *SpriteLayer {
+
+	anim := NewAnimationComponent(animPath)
+	// Only the GameObject's own animation moves its hurtbox and muzzle around
+	anim.Slices = map[string]*Slice{}
+	anim.Play("Anim")
+
+	return &SpriteLayer{
+		Name:      name,
+		Animation: anim,
+		Offset:    vector.Vector{offsetX, offsetY},
+		Layer:     RenderLayerGameObject,
+		DepthBias: depthBias,
+		Flip:      true,
+		Alpha:     1,
+		Visible:   true,
+	}
+
+}
+
+// NewShadowLayer is a drop shadow for a body of the given height, sitting at its feet on the floor, under everything
+// standing near it.
+func NewShadowLayer(bodyHeight float64) *SpriteLayer {
+	shadow := NewSpriteLayer("shadow", "assets/shadow.json", 0, bodyHeight/2, 0)
+	shadow.Layer = RenderLayerFloor
+	shadow.Flip = false
+	shadow.Alpha = 0.5
+	return shadow
+}
+
+// queuedSprite is a sprite waiting to be drawn once everything in the Level's been sorted by depth.
+type queuedSprite struct {
+	Key     RenderKey
+	Image   *ebiten.Image
+	Options *ebiten.DrawImageOptions
+}
+
+// spriteQueue sorts queued sprites by their keys, which are worked out once when they're queued.
+type spriteQueue []queuedSprite
+
+func (q spriteQueue) Len() int           { return len(q) }
+func (q spriteQueue) Less(i, j int) bool { return q[i].Key.Less(q[j].Key) }
+func (q spriteQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/kvartborg/vector"
+)
+
+// Steering mixes an NPC's movement from weighted steering behaviors. Seeking (or arriving at) where the NPC wants to go
+// comes from its MoveDirection; on top of that it keeps away from other NPCs, steers around cells it can't walk into
+// and slides along walls it's heading into. The result has a magnitude of at most 1, and is used as the NPC's thrust.
+// Under half of ArriveRadius, that thrust can't beat friction from a standstill, so ArriveRadius shouldn't be more than
+// twice the margin NPCs check their arrival with.
+type Steering struct {
+	SeekWeight       float64
+	SeparationWeight float64
+	AvoidWeight      float64
+	WallFollowWeight float64
+	SeparationRadius float64 // How close other NPCs can get before they're pushed away
+	LookAhead        float64 // How far ahead of itself the NPC checks for walls
+	ArriveRadius     float64 // How far from the end of its path the NPC starts slowing down
+}
+
+func NewSteering() *Steering {
+	return &Steering{
+		SeekWeight:       1,
+		SeparationWeight: 1.5,
+		AvoidWeight:      0.75,
+		WallFollowWeight: 0.5,
+		SeparationRadius: 14,
+		LookAhead:        12,
+		ArriveRadius:     8,
+	}
+}
+
+// Steer returns the combined steering for the NPC this frame.
+func (s *Steering) Steer(ai *AIControlComponent) vector.Vector {
+
+	level := ai.GameObject.Level
+	pos := ai.Position()
+	heading := ai.MoveDirection.Clone()
+
+	steer := heading.Clone().Scale(s.SeekWeight)
+
+	steer.Add(Separation(level, ai.GameObject, pos, s.SeparationRadius).Scale(s.SeparationWeight))
+
+	if heading.Magnitude() > 0 {
+		steer.Add(ObstacleAvoidance(level, pos, heading, s.LookAhead).Scale(s.AvoidWeight))
+		steer.Add(WallFollow(level, pos, heading, s.LookAhead).Scale(s.WallFollowWeight))
+	}
+
+	// Tiny pushes would just make idle NPCs jitter in place.
+	if steer.Magnitude() < 0.1 {
+		return vector.Vector{0, 0}
+	}
+
+	if steer.Magnitude() > 1 {
+		steer.Unit()
+	}
+
+	return steer
+
+}
+
+// Seek returns a unit vector pointing from the position to the target.
+func Seek(pos, target vector.Vector) vector.Vector {
+	return vector.Sub(target, pos).Unit()
+}
+
+// Arrive is like Seek, but tapers off as the position comes within slowRadius of the target.
+func Arrive(pos, target vector.Vector, slowRadius float64) vector.Vector {
+
+	diff := vector.Sub(target, pos)
+	dist := diff.Magnitude()
+
+	if dist < slowRadius {
+		return diff.Unit().Scale(dist / slowRadius)
+	}
+
+	return diff.Unit()
+
+}
+
+// Separation pushes the GameObject away from other NPCs within radius of its position, harder the closer they are.
+func Separation(level *Level, self *GameObject, pos vector.Vector, radius float64) vector.Vector {
+
+	push := vector.Vector{0, 0}
+
+	for _, other := range level.Spatial.QueryRadius(pos[0], pos[1], radius) {
+
+		if other == self || other.GetComponent(TypeAIControlComponent) == nil {
+			continue
+		}
+
+		ox, oy, _ := level.Spatial.Position(other)
+		away := vector.Vector{pos[0] - ox, pos[1] - oy}
+		dist := away.Magnitude()
+
+		// Perfectly stacked; any way out will do.
+		if dist == 0 {
+			angle := rand.Float64() * math.Pi * 2
+			away = vector.Vector{math.Cos(angle), math.Sin(angle)}
+		}
+
+		push.Add(away.Unit().Scale((radius - dist) / radius))
+
+	}
+
+	return push
+
+}
+
+// ObstacleAvoidance looks ahead of the position along the heading and, if there's a cell there that can't be walked
+// into, pushes away from it; harder if it's close.
+func ObstacleAvoidance(level *Level, pos, heading vector.Vector, lookAhead float64) vector.Vector {
+
+	for _, reach := range []float64{0.5, 1} {
+
+		probe := vector.Add(pos, vector.Unit(heading).Scale(lookAhead*reach))
+		cx, cy := level.CellAt(probe[0], probe[1])
+
+		if !level.Walkable(cx, cy) {
+			return wallNormal(pos, cx, cy).Scale(1.5 - reach)
+		}
+
+	}
+
+	return vector.Vector{0, 0}
+
+}
+
+// WallFollow returns a direction running along the wall the heading leads into, on whichever side is closer to the
+// heading, or nothing if there's no wall within lookAhead.
+func WallFollow(level *Level, pos, heading vector.Vector, lookAhead float64) vector.Vector {
+
+	probe := vector.Add(pos, vector.Unit(heading).Scale(lookAhead))
+	cx, cy := level.CellAt(probe[0], probe[1])
+
+	if level.Walkable(cx, cy) {
+		return vector.Vector{0, 0}
+	}
+
+	normal := wallNormal(pos, cx, cy)
+	along := vector.Vector{-normal[1], normal[0]}
+
+	if along.Dot(heading) < 0 {
+		along.Scale(-1)
+	}
+
+	return along
+
+}
+
+// wallNormal returns the axis-aligned direction pointing out of the side of the cell at x, y facing the position.
+func wallNormal(pos vector.Vector, x, y int) vector.Vector {
+
+	dx := pos[0] - float64(x*16+8)
+	dy := pos[1] - float64(y*16+8)
+
+	if math.Abs(dx) > math.Abs(dy) {
+		return vector.Vector{math.Copysign(1, dx), 0}
+	}
+
+	return vector.Vector{0, math.Copysign(1, dy)}
+
+}
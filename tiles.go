@@ -0,0 +1,180 @@
+package main
+
+import (
+	"math"
+
+	"github.com/SolarLune/paths"
+	"github.com/SolarLune/resolv"
+)
+
+// CellType holds the gameplay side of a map rune. Tags go on the cell's resolv Object (cells without Tags don't get
+// one), Walkable and Cost go into the pathfinding grid, SpeedModifier and Friction scale the movement of walking
+// bodies, and Opaque cells block the player's view through the fog of war.
+type CellType struct {
+	Tags          []string
+	Walkable      bool
+	Cost          float64
+	SpeedModifier float64
+	Friction      float64
+	Opaque        bool
+}
+
+var CellTypes = map[rune]CellType{
+	FLOOR:        {Walkable: true, Cost: 1, SpeedModifier: 1, Friction: 1},
+	WALL:         {Tags: []string{"solid"}, SpeedModifier: 1, Friction: 1, Opaque: true},
+	CRACKED_WALL: {Tags: []string{"solid", "destructible"}, SpeedModifier: 1, Friction: 1, Opaque: true},
+	DOOR_CLOSED:  {Tags: []string{"solid", "door"}, Walkable: true, Cost: 2, SpeedModifier: 1, Friction: 1, Opaque: true}, // NPCs open doors they bump into
+	DOOR_OPEN:    {Walkable: true, Cost: 1, SpeedModifier: 1, Friction: 1},
+	WATER:        {Walkable: true, Cost: 4, SpeedModifier: 0.5, Friction: 1.5},
+	PIT:          {Tags: []string{"pit"}, SpeedModifier: 1, Friction: 1},
+}
+
+func (level *Level) CellType(x, y int) CellType {
+	if ct, exists := CellTypes[level.Map.Get(x, y)]; exists {
+		return ct
+	}
+	return CellType{SpeedModifier: 1, Friction: 1}
+}
+
+// Opaque returns if the cell at x, y blocks sight.
+func (level *Level) Opaque(x, y int) bool {
+	return level.CellType(x, y).Opaque
+}
+
+// Walkable returns if NPCs can walk into the cell at x, y, going by the pathfinding grid.
+func (level *Level) Walkable(x, y int) bool {
+	cell := level.PathfindingGrid.Get(x, y)
+	return cell != nil && cell.Walkable
+}
+
+// InitCells creates the collision Objects and pathfinding grid for the whole map.
+func (level *Level) InitCells() {
+
+	level.TileObjects = make([][]*resolv.Object, level.Map.Height)
+	for y := range level.TileObjects {
+		level.TileObjects[y] = make([]*resolv.Object, level.Map.Width)
+	}
+
+	level.PathfindingGrid = paths.NewGridFromRuneArrays(level.Map.Data, 16, 16)
+
+	for y := 0; y < level.Map.Height; y++ {
+		for x := 0; x < level.Map.Width; x++ {
+			level.updateCell(x, y)
+		}
+	}
+
+}
+
+// SetCell changes the map cell at x, y, updating its collision, pathfinding and tile graphics to match.
+func (level *Level) SetCell(x, y int, value rune) {
+
+	if x < 0 || y < 0 || x >= level.Map.Width || y >= level.Map.Height {
+		return
+	}
+
+	level.Map.Set(x, y, value)
+	level.updateCell(x, y)
+	level.RenderTilesAround(x, y)
+
+}
+
+func (level *Level) updateCell(x, y int) {
+
+	ct := level.CellType(x, y)
+
+	if obj := level.TileObjects[y][x]; obj != nil {
+		obj.Remove()
+		level.TileObjects[y][x] = nil
+	}
+
+	if len(ct.Tags) > 0 {
+		obj := resolv.NewObject(float64(x*16), float64(y*16), 16, 16, level.Space)
+		obj.AddTag(ct.Tags...)
+		level.TileObjects[y][x] = obj
+	}
+
+	if cell := level.PathfindingGrid.Get(x, y); cell != nil {
+		cell.Walkable = ct.Walkable
+		cell.Cost = ct.Cost
+	}
+
+	if level.FlowField != nil {
+		level.FlowField.Dirty = true
+	}
+
+	if level.Fog != nil {
+		level.Fog.Dirty = true
+	}
+
+}
+
+// ToggleDoor opens or closes the door at x, y. A door can't close on something standing in it. It returns true if
+// the door was toggled.
+func (level *Level) ToggleDoor(x, y int) bool {
+
+	switch level.Map.Get(x, y) {
+	case DOOR_OPEN:
+		if cell := level.Space.Cell(x, y); cell != nil && cell.Occupied() {
+			return false
+		}
+		level.SetCell(x, y, DOOR_CLOSED)
+		return true
+	case DOOR_CLOSED:
+		level.SetCell(x, y, DOOR_OPEN)
+		return true
+	}
+
+	return false
+
+}
+
+// DestroyCellsInRect breaks any destructible cells the rectangle overlaps, turning them into floor.
+func (level *Level) DestroyCellsInRect(x, y, w, h float64) {
+
+	for _, c := range level.CellsInRect(x, y, w, h) {
+		if level.Map.Get(c[0], c[1]) == CRACKED_WALL {
+			level.SetCell(c[0], c[1], FLOOR)
+			Explode(level, float64(c[0]*16+8), float64(c[1]*16+8), nil)
+		}
+	}
+
+}
+
+// OpenDoorsInRect opens any closed doors the rectangle overlaps.
+func (level *Level) OpenDoorsInRect(x, y, w, h float64) {
+
+	for _, c := range level.CellsInRect(x, y, w, h) {
+		if level.Map.Get(c[0], c[1]) == DOOR_CLOSED {
+			level.ToggleDoor(c[0], c[1])
+		}
+	}
+
+}
+
+// CellsInRect returns the positions of the map cells the world-space rectangle overlaps.
+func (level *Level) CellsInRect(x, y, w, h float64) [][]int {
+
+	cells := [][]int{}
+
+	sx, sy := level.CellAt(x, y)
+	ex, ey := level.CellAt(x+w-1, y+h-1)
+
+	for cy := sy; cy <= ey; cy++ {
+		for cx := sx; cx <= ex; cx++ {
+			cells = append(cells, []int{cx, cy})
+		}
+	}
+
+	return cells
+
+}
+
+// CellAt returns the map cell that contains the world position x, y.
+func (level *Level) CellAt(x, y float64) (int, int) {
+	return int(math.Floor(x / 16)), int(math.Floor(y / 16))
+}
+
+// SurfaceAt returns the CellType of the cell under the world position x, y.
+func (level *Level) SurfaceAt(x, y float64) CellType {
+	return level.CellType(level.CellAt(x, y))
+}
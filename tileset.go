@@ -0,0 +1,414 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+
+	"github.com/SolarLune/dngn"
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Tile layers; BG tiles are drawn under GameObjects, FG tiles over them.
+const (
+	TileLayerBG = "bg"
+	TileLayerFG = "fg"
+)
+
+// Neighbor bits, clockwise from north, as used by blob (47-tile) masks.
+const (
+	NeighborN = 1 << iota
+	NeighborNE
+	NeighborE
+	NeighborSE
+	NeighborS
+	NeighborSW
+	NeighborW
+	NeighborNW
+)
+
+// neighborOffsets lines up with the Neighbor bits above.
+var neighborOffsets = [8][2]int{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+
+// patternOffsets lines up with the characters of a rule's 3x3 pattern, read row by row.
+var patternOffsets = [9][2]int{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {0, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}}
+
+// Tileset is an autotiling ruleset loaded from a JSON file next to the tileset image. Each Terrain maps a
+// map rune to the rules that pick which tile (and which weighted variant) gets drawn for a cell.
+type Tileset struct {
+	Image    *ebiten.Image
+	TileSize int
+	Seed     uint32
+	Groups   map[rune]string
+	Terrains map[rune]*Terrain
+}
+
+// Terrain describes how one map rune gets drawn. A Terrain can borrow the rules of another Terrain through
+// Like, and tint everything it draws with Color (an RGBA scale, where 1 leaves the channel as-is).
+type Terrain struct {
+	Rune         rune
+	Layer        string
+	Connects     string
+	EdgesConnect bool
+	Rules        []*TileRule
+	Blob         *BlobLayout
+	Color        []float64
+}
+
+// TileRule matches a 3x3 Pattern around a cell. In a pattern, "?" matches anything, "#" matches a connected
+// terrain, "!" matches anything that isn't connected, and any other character matches the runes of the
+// Tileset group of the same name. The center character is ignored.
+type TileRule struct {
+	Pattern []string
+	Tiles   []*TileVariant
+}
+
+type TileVariant struct {
+	X, Y     int
+	Rotation int // Quarter turns clockwise
+	Weight   float64
+	Color    []float64
+}
+
+// BlobLayout lays out a full 47-tile blob set on the tileset image, starting at X, Y and wrapping after Columns
+// tiles. Order lists the reduced neighbor mask each tile represents; if it's empty, the masks go in ascending order.
+type BlobLayout struct {
+	X, Y    int
+	Columns int
+	Order   []int
+	tiles   map[int]*TileVariant
+}
+
+type tilesetFile struct {
+	Image    string
+	TileSize int
+	Groups   map[string]string
+	Terrains []struct {
+		Rune         string
+		Layer        string
+		Connects     string
+		EdgesConnect bool
+		Rules        []*TileRule
+		Blob         *BlobLayout
+		Like         string
+		Color        []float64
+	}
+}
+
+func LoadTileset(rulesPath string) (*Tileset, error) {
+
+	data, err := ioutil.ReadFile(getPath(rulesPath))
+	if err != nil {
+		return nil, err
+	}
+
+	file := tilesetFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	ts := &Tileset{
+		Image:    GetImage(filepath.ToSlash(filepath.Join(filepath.Dir(rulesPath), file.Image))),
+		TileSize: file.TileSize,
+		Seed:     rand.Uint32(),
+		Groups:   map[rune]string{},
+		Terrains: map[rune]*Terrain{},
+	}
+
+	if ts.TileSize == 0 {
+		ts.TileSize = 16
+	}
+
+	for name, runes := range file.Groups {
+		if name == "" {
+			return nil, fmt.Errorf("%s: tile group with a blank name", rulesPath)
+		}
+		ts.Groups[[]rune(name)[0]] = runes
+	}
+
+	for i, t := range file.Terrains {
+
+		if t.Rune == "" {
+			return nil, fmt.Errorf("%s: terrain %d has no rune", rulesPath, i)
+		}
+
+		terrain := &Terrain{
+			Rune:         []rune(t.Rune)[0],
+			Layer:        t.Layer,
+			Connects:     t.Connects,
+			EdgesConnect: t.EdgesConnect,
+			Rules:        t.Rules,
+			Blob:         t.Blob,
+			Color:        t.Color,
+		}
+
+		if terrain.Layer == "" {
+			terrain.Layer = TileLayerBG
+		}
+
+		if terrain.Connects == "" {
+			terrain.Connects = string(terrain.Rune)
+		}
+
+		if terrain.Blob != nil {
+			terrain.Blob.build(ts.TileSize)
+		}
+
+		ts.Terrains[terrain.Rune] = terrain
+
+	}
+
+	for _, t := range file.Terrains {
+		if t.Like == "" {
+			continue
+		}
+		terrain := ts.Terrains[[]rune(t.Rune)[0]]
+		if like, exists := ts.Terrains[[]rune(t.Like)[0]]; exists {
+			if len(terrain.Rules) == 0 {
+				terrain.Rules = like.Rules
+			}
+			if terrain.Blob == nil {
+				terrain.Blob = like.Blob
+			}
+		}
+	}
+
+	return ts, nil
+
+}
+
+// NewFallbackTileset is a plain Tileset for when the rules file can't be loaded, so the map can still be seen. Cells
+// that block sight are drawn as one wall tile and everything else as one floor tile, with no autotiling.
+func NewFallbackTileset(imagePath string) *Tileset {
+
+	ts := &Tileset{
+		Image:    GetImage(imagePath),
+		TileSize: 16,
+		Seed:     rand.Uint32(),
+		Groups:   map[rune]string{},
+		Terrains: map[rune]*Terrain{},
+	}
+
+	// Rules without a pattern match any cell
+	floor := []*TileRule{{Tiles: []*TileVariant{{X: 0, Y: 16}}}}
+	wall := []*TileRule{{Tiles: []*TileVariant{{X: 16, Y: 16}}}}
+
+	for r, ct := range CellTypes {
+		terrain := &Terrain{Rune: r, Layer: TileLayerBG, Connects: string(r), Rules: floor}
+		if ct.Opaque {
+			terrain.Layer, terrain.Rules = TileLayerFG, wall
+		}
+		ts.Terrains[r] = terrain
+	}
+
+	return ts
+
+}
+
+// Tile returns the Terrain and the TileVariant to draw for the cell at x, y in the room, or nils if the cell's rune
+// has no Terrain or no rule matches.
+func (ts *Tileset) Tile(room *dngn.Room, x, y int) (*Terrain, *TileVariant) {
+
+	terrain, exists := ts.Terrains[room.Get(x, y)]
+
+	if !exists {
+		return nil, nil
+	}
+
+	for _, rule := range terrain.Rules {
+		if ts.ruleMatches(rule, terrain, room, x, y) {
+			return terrain, ts.pickVariant(rule.Tiles, x, y)
+		}
+	}
+
+	if terrain.Blob != nil {
+		if tile, exists := terrain.Blob.tiles[BlobMask(terrain.NeighborMask(room, x, y))]; exists {
+			return terrain, tile
+		}
+	}
+
+	return terrain, nil
+
+}
+
+// DrawTile draws the given TileVariant of the Terrain onto the destination image with its top-left corner at x, y.
+func (ts *Tileset) DrawTile(dst *ebiten.Image, terrain *Terrain, tile *TileVariant, x, y float64) {
+
+	size := ts.TileSize
+	sub := ts.Image.SubImage(image.Rect(tile.X, tile.Y, tile.X+size, tile.Y+size)).(*ebiten.Image)
+
+	half := float64(size) / 2
+
+	geoM := ebiten.GeoM{}
+	geoM.Translate(-half, -half)
+	geoM.Rotate(float64(tile.Rotation) * math.Pi / 2)
+	geoM.Translate(half, half)
+	geoM.Translate(x, y)
+
+	colorM := ebiten.ColorM{}
+
+	tint := tile.Color
+	if len(tint) == 0 {
+		tint = terrain.Color
+	}
+	if len(tint) == 4 {
+		colorM.Scale(tint[0], tint[1], tint[2], tint[3])
+	}
+
+	dst.DrawImage(sub, &ebiten.DrawImageOptions{GeoM: geoM, ColorM: colorM})
+
+}
+
+func (ts *Tileset) ruleMatches(rule *TileRule, terrain *Terrain, room *dngn.Room, x, y int) bool {
+
+	for i, offset := range patternOffsets {
+
+		if i == 4 || len(rule.Pattern) <= i/3 || len(rule.Pattern[i/3]) <= i%3 {
+			continue
+		}
+
+		nx, ny := x+offset[0], y+offset[1]
+
+		switch c := rune(rule.Pattern[i/3][i%3]); c {
+		case '?':
+		case '#':
+			if !terrain.ConnectsTo(room, nx, ny) {
+				return false
+			}
+		case '!':
+			if terrain.ConnectsTo(room, nx, ny) {
+				return false
+			}
+		default:
+			if !containsRune(ts.Groups[c], room.Get(nx, ny)) {
+				return false
+			}
+		}
+
+	}
+
+	return true
+
+}
+
+// pickVariant picks one of the tiles by weight. The pick is seeded by the cell position, so re-rendering a cell
+// always gives the same variant.
+func (ts *Tileset) pickVariant(tiles []*TileVariant, x, y int) *TileVariant {
+
+	if len(tiles) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	for _, t := range tiles {
+		total += t.weight()
+	}
+
+	h := uint32(x)*73856093 ^ uint32(y)*19349663 ^ ts.Seed
+	h ^= h >> 16
+	h *= 0x45d9f3b
+	h ^= h >> 16
+
+	roll := float64(h) / float64(math.MaxUint32) * total
+
+	for _, t := range tiles {
+		roll -= t.weight()
+		if roll < 0 {
+			return t
+		}
+	}
+
+	return tiles[len(tiles)-1]
+
+}
+
+func (terrain *Terrain) ConnectsTo(room *dngn.Room, x, y int) bool {
+	if x < 0 || y < 0 || x >= room.Width || y >= room.Height {
+		return terrain.EdgesConnect
+	}
+	return containsRune(terrain.Connects, room.Get(x, y))
+}
+
+// NeighborMask returns the Neighbor bits for each of the 8 neighbors of x, y that the Terrain connects to.
+func (terrain *Terrain) NeighborMask(room *dngn.Room, x, y int) int {
+	mask := 0
+	for i, offset := range neighborOffsets {
+		if terrain.ConnectsTo(room, x+offset[0], y+offset[1]) {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+// BlobMask reduces an 8-neighbor mask to one of the 47 blob tiles by dropping corners that aren't touching
+// both of their adjacent edges.
+func BlobMask(mask int) int {
+
+	corners := [][3]int{
+		{NeighborNE, NeighborN, NeighborE},
+		{NeighborSE, NeighborS, NeighborE},
+		{NeighborSW, NeighborS, NeighborW},
+		{NeighborNW, NeighborN, NeighborW},
+	}
+
+	for _, c := range corners {
+		if mask&c[1] == 0 || mask&c[2] == 0 {
+			mask &^= c[0]
+		}
+	}
+
+	return mask
+
+}
+
+func (blob *BlobLayout) build(tileSize int) {
+
+	order := blob.Order
+
+	if len(order) == 0 {
+		seen := map[int]bool{}
+		for mask := 0; mask < 256; mask++ {
+			if m := BlobMask(mask); !seen[m] {
+				seen[m] = true
+				order = append(order, m)
+			}
+		}
+		sort.Ints(order)
+	}
+
+	columns := blob.Columns
+	if columns <= 0 {
+		columns = 8
+	}
+
+	blob.tiles = map[int]*TileVariant{}
+
+	for i, mask := range order {
+		blob.tiles[mask] = &TileVariant{
+			X: blob.X + (i%columns)*tileSize,
+			Y: blob.Y + (i/columns)*tileSize,
+		}
+	}
+
+}
+
+func (t *TileVariant) weight() float64 {
+	if t.Weight <= 0 {
+		return 1
+	}
+	return t.Weight
+}
+
+func containsRune(runes string, r rune) bool {
+	for _, c := range runes {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
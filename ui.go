@@ -0,0 +1,152 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten"
+)
+
+// Anchors place a widget against an edge or corner of the screen, or its middle.
+const (
+	AnchorTopLeft = iota
+	AnchorTop
+	AnchorTopRight
+	AnchorLeft
+	AnchorCenter
+	AnchorRight
+	AnchorBottomLeft
+	AnchorBottom
+	AnchorBottomRight
+)
+
+// Placement puts a widget on the screen: against its Anchor, moved in by X and Y (towards the middle of the screen,
+// so a margin is always positive).
+type Placement struct {
+	Anchor int
+	X, Y   float64
+}
+
+// Position returns where the top-left corner of a widget of the given size goes on a screen of the given size.
+func (p Placement) Position(screenW, screenH, w, h float64) (float64, float64) {
+
+	x, y := p.X, p.Y
+
+	switch p.Anchor % 3 {
+	case 1:
+		x = (screenW-w)/2 + p.X
+	case 2:
+		x = screenW - w - p.X
+	}
+
+	switch p.Anchor / 3 {
+	case 1:
+		y = (screenH-h)/2 + p.Y
+	case 2:
+		y = screenH - h - p.Y
+	}
+
+	return x, y
+
+}
+
+// Widget is a piece of the UI. Widgets are drawn in screen space, over the Level, in the order they were added.
+type Widget interface {
+	Update(ui *UI)
+	Draw(screen *ebiten.Image, ui *UI)
+}
+
+// UI is the layer of widgets drawn over the Level. It's laid out against the Game's Width and Height, so it follows
+// along when the resolution changes.
+type UI struct {
+	Game     *Game
+	Font     *BitmapFont
+	Widgets  []Widget
+	Messages *MessageLog
+}
+
+func NewUI(game *Game) *UI {
+
+	ui := &UI{
+		Game:     game,
+		Font:     GetFont("assets/font.json"),
+		Messages: NewMessageLog(),
+	}
+
+	ui.Add(
+		NewHealthBarWidget(),
+		NewAmmoWidget(),
+		NewFloorWidget(),
+		NewEnemyHealthBarsWidget(),
+		NewMinimapWidget(),
+		ui.Messages,
+		NewFPSWidget(),
+	)
+
+	return ui
+
+}
+
+func (ui *UI) Add(widgets ...Widget) {
+	ui.Widgets = append(ui.Widgets, widgets...)
+}
+
+func (ui *UI) Remove(widget Widget) {
+	for i, w := range ui.Widgets {
+		if w == widget {
+			ui.Widgets = append(ui.Widgets[:i], ui.Widgets[i+1:]...)
+			return
+		}
+	}
+}
+
+// Message shows a line of text in the message log.
+func (ui *UI) Message(text string) {
+	ui.Messages.Add(text)
+}
+
+// Size returns how big the screen the UI's drawn on is.
+func (ui *UI) Size() (float64, float64) {
+	return float64(ui.Game.Width), float64(ui.Game.Height)
+}
+
+// Place returns where the top-left corner of a widget of the given size goes.
+func (ui *UI) Place(p Placement, w, h float64) (float64, float64) {
+	screenW, screenH := ui.Size()
+	return p.Position(screenW, screenH, w, h)
+}
+
+// Player returns the current Level's player, or nil if there isn't one.
+func (ui *UI) Player() *GameObject {
+	if ui.Game.Level == nil {
+		return nil
+	}
+	return ui.Game.Level.Player
+}
+
+// Text draws text with a drop shadow in the UI's font, if it's got one.
+func (ui *UI) Text(screen *ebiten.Image, text string, x, y float64, clr color.Color) {
+	if ui.Font != nil {
+		ui.Font.DrawShadowed(screen, text, x, y, clr)
+	}
+}
+
+// MeasureText returns how wide and tall the text is in the UI's font.
+func (ui *UI) MeasureText(text string) (float64, float64) {
+	if ui.Font == nil {
+		return 0, 0
+	}
+	w, h := ui.Font.Measure(text)
+	return float64(w), float64(h)
+}
+
+func (ui *UI) Update() {
+	for _, w := range ui.Widgets {
+		w.Update(ui)
+	}
+}
+
+func (ui *UI) Draw(screen *ebiten.Image) {
+	for _, w := range ui.Widgets {
+		w.Draw(screen, ui)
+	}
+}
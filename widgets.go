@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// drawBar draws a bar filled up to the fraction (from 0 to 1), with a dark background and outline.
+func drawBar(screen *ebiten.Image, x, y, w, h, fraction float64, fill color.Color) {
+	ebitenutil.DrawRect(screen, x-1, y-1, w+2, h+2, color.RGBA{10, 8, 16, 255})
+	ebitenutil.DrawRect(screen, x, y, w, h, color.RGBA{50, 40, 60, 255})
+	ebitenutil.DrawRect(screen, x, y, math.Round(w*math.Max(0, math.Min(fraction, 1))), h, fill)
+}
+
+// HealthBarWidget shows how much health the player has left.
+type HealthBarWidget struct {
+	Placement     Placement
+	Width, Height float64
+	Color         color.Color
+	LowColor      color.Color // The bar's color once the player's down to a quarter of their health
+}
+
+func NewHealthBarWidget() *HealthBarWidget {
+	return &HealthBarWidget{
+		Placement: Placement{Anchor: AnchorTopLeft, X: 8, Y: 8},
+		Width:     64,
+		Height:    6,
+		Color:     color.RGBA{80, 200, 90, 255},
+		LowColor:  color.RGBA{220, 60, 50, 255},
+	}
+}
+
+func (hb *HealthBarWidget) Update(ui *UI) {}
+
+func (hb *HealthBarWidget) Draw(screen *ebiten.Image, ui *UI) {
+
+	player := ui.Player()
+	if player == nil {
+		return
+	}
+
+	h := player.GetComponent(TypeHealthComponent)
+	if h == nil {
+		return
+	}
+
+	health := h.(*HealthComponent)
+	text := fmt.Sprintf("%.0f/%.0f", health.HP, health.MaxHP)
+	textW, textH := ui.MeasureText(text)
+
+	x, y := ui.Place(hb.Placement, hb.Width+4+textW, math.Max(hb.Height, textH))
+
+	fill := hb.Color
+	if health.Fraction() <= 0.25 {
+		fill = hb.LowColor
+	}
+
+	drawBar(screen, x, y, hb.Width, hb.Height, health.Fraction(), fill)
+	ui.Text(screen, text, x+hb.Width+4, y-1, color.White)
+
+}
+
+// AmmoWidget shows how many shots the player's weapon has left, or that it's reloading.
+type AmmoWidget struct {
+	Placement Placement
+}
+
+func NewAmmoWidget() *AmmoWidget {
+	return &AmmoWidget{Placement: Placement{Anchor: AnchorTopLeft, X: 8, Y: 20}}
+}
+
+func (aw *AmmoWidget) Update(ui *UI) {}
+
+func (aw *AmmoWidget) Draw(screen *ebiten.Image, ui *UI) {
+
+	player := ui.Player()
+	if player == nil {
+		return
+	}
+
+	wc := player.GetComponent(TypeWeaponComponent)
+	if wc == nil {
+		return
+	}
+
+	weapon := wc.(*WeaponComponent)
+
+	text := "AMMO --"
+	clr := color.Color(color.White)
+
+	if weapon.Reloading > 0 {
+		text = "RELOADING"
+		clr = color.RGBA{255, 200, 80, 255}
+	} else if weapon.MaxAmmo > 0 {
+		text = fmt.Sprintf("AMMO %d/%d", weapon.Ammo, weapon.MaxAmmo)
+	}
+
+	w, h := ui.MeasureText(text)
+	x, y := ui.Place(aw.Placement, w, h)
+	ui.Text(screen, text, x, y, clr)
+
+}
+
+// FloorWidget shows which floor of the dungeon the player's on.
+type FloorWidget struct {
+	Placement Placement
+}
+
+func NewFloorWidget() *FloorWidget {
+	return &FloorWidget{Placement: Placement{Anchor: AnchorBottomLeft, X: 8, Y: 8}}
+}
+
+func (fw *FloorWidget) Update(ui *UI) {}
+
+func (fw *FloorWidget) Draw(screen *ebiten.Image, ui *UI) {
+
+	if ui.Game.Level == nil {
+		return
+	}
+
+	text := fmt.Sprintf("FLOOR %d", ui.Game.Level.Floor)
+	w, h := ui.MeasureText(text)
+	x, y := ui.Place(fw.Placement, w, h)
+	ui.Text(screen, text, x, y, color.White)
+
+}
+
+// EnemyHealthBarsWidget draws a small health bar over each NPC in view that's been hurt.
+type EnemyHealthBarsWidget struct {
+	Width, Height float64
+	Color         color.Color
+}
+
+func NewEnemyHealthBarsWidget() *EnemyHealthBarsWidget {
+	return &EnemyHealthBarsWidget{Width: 12, Height: 2, Color: color.RGBA{220, 60, 50, 255}}
+}
+
+func (eb *EnemyHealthBarsWidget) Update(ui *UI) {}
+
+func (eb *EnemyHealthBarsWidget) Draw(screen *ebiten.Image, ui *UI) {
+
+	level := ui.Game.Level
+	if level == nil {
+		return
+	}
+
+	for _, g := range level.GameObjects {
+
+		if g == level.Player || g.GetComponent(TypeAIControlComponent) == nil {
+			continue
+		}
+
+		h, b := g.GetComponent(TypeHealthComponent), g.GetComponent(TypeBodyComponent)
+		if h == nil || b == nil {
+			continue
+		}
+
+		health, body := h.(*HealthComponent), b.(*BodyComponent)
+		if health.HP >= health.MaxHP || health.HP <= 0 {
+			continue
+		}
+
+		center := body.Center()
+		if level.Fog != nil && !level.Fog.VisibleAt(center[0], center[1]) {
+			continue
+		}
+
+		if !level.InView(body.Object.X, body.Object.Y, body.Object.W, body.Object.H) {
+			continue
+		}
+
+		x, y := level.Camera.WorldToScreen(center[0], body.Object.Y-8)
+		drawBar(screen, math.Round(x-eb.Width/2), math.Round(y), eb.Width, eb.Height, health.Fraction(), eb.Color)
+
+	}
+
+}
+
+type message struct {
+	Text string
+	Age  int
+}
+
+// MessageLog shows the latest few messages, each fading out once it's been up for Duration frames.
+type MessageLog struct {
+	Placement Placement
+	Duration  int
+	FadeTime  int
+	MaxLines  int
+	messages  []message
+}
+
+func NewMessageLog() *MessageLog {
+	return &MessageLog{
+		Placement: Placement{Anchor: AnchorBottom, Y: 24},
+		Duration:  180,
+		FadeTime:  30,
+		MaxLines:  4,
+	}
+}
+
+func (ml *MessageLog) Add(text string) {
+	ml.messages = append(ml.messages, message{Text: text})
+	if len(ml.messages) > ml.MaxLines {
+		ml.messages = ml.messages[len(ml.messages)-ml.MaxLines:]
+	}
+}
+
+func (ml *MessageLog) Update(ui *UI) {
+
+	messages := ml.messages[:0]
+
+	for _, m := range ml.messages {
+		m.Age++
+		if m.Age < ml.Duration+ml.FadeTime {
+			messages = append(messages, m)
+		}
+	}
+
+	ml.messages = messages
+
+}
+
+func (ml *MessageLog) Draw(screen *ebiten.Image, ui *UI) {
+
+	if ui.Font == nil || len(ml.messages) == 0 {
+		return
+	}
+
+	lineHeight := float64(ui.Font.LineHeight)
+
+	for i, m := range ml.messages {
+
+		w, h := ui.MeasureText(m.Text)
+		x, y := ui.Place(ml.Placement, w, h)
+		y -= float64(len(ml.messages)-1-i) * lineHeight
+
+		alpha := 1.0
+		if m.Age > ml.Duration {
+			alpha = 1 - float64(m.Age-ml.Duration)/float64(ml.FadeTime)
+		}
+
+		ui.Text(screen, m.Text, x, y, color.NRGBA{255, 255, 255, uint8(255 * alpha)})
+
+	}
+
+}
+
+// FPSWidget shows the frame and tick rates in debug mode.
+type FPSWidget struct {
+	Placement Placement
+}
+
+func NewFPSWidget() *FPSWidget {
+	return &FPSWidget{Placement: Placement{Anchor: AnchorBottomRight, X: 8, Y: 8}}
+}
+
+func (fw *FPSWidget) Update(ui *UI) {}
+
+func (fw *FPSWidget) Draw(screen *ebiten.Image, ui *UI) {
+
+	if !ui.Game.DebugMode {
+		return
+	}
+
+	text := fmt.Sprintf("FPS %.0f\nTPS %.0f", ebiten.CurrentFPS(), ebiten.CurrentTPS())
+	w, h := ui.MeasureText(text)
+	x, y := ui.Place(fw.Placement, w, h)
+	ui.Text(screen, text, x, y, color.White)
+
+}